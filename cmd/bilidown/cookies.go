@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download/cookie"
+)
+
+func runCookies(args []string) {
+	fs := flag.NewFlagSet("cookies", flag.ExitOnError)
+	var format string
+	cf := newConfigFlags(fs)
+	fs.StringVar(&format, "format", "netscape", "export format, json or netscape")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: bilidown cookies import [-profile name] <file>\n")
+		fmt.Fprintf(fs.Output(), "       bilidown cookies export [-profile name] [-format json|netscape] <file>\n")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cmd := args[0]
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	file := fs.Arg(0)
+	cf.load()
+
+	switch cmd {
+	case "import":
+		if err := cookie.LoadFile(file); err != nil {
+			log.Errorf("import cookies from %v error: %v", file, err)
+			os.Exit(1)
+		}
+		cookie.SaveCookies()
+		log.Infof("import cookies from %v success", file)
+	case "export":
+		if format != "json" && format != "netscape" {
+			log.Errorf("unsupported format %v", format)
+			os.Exit(2)
+		}
+		if err := cookie.ExportFile(file, format); err != nil {
+			log.Errorf("export cookies to %v error: %v", file, err)
+			os.Exit(1)
+		}
+		log.Infof("export cookies to %v success", file)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	stdlog "log"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/config"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/download/cookie/browser"
+	"github.com/rammiah/bili-downloader/outtmpl"
+	"github.com/rammiah/bili-downloader/progress"
+	"github.com/rammiah/bili-downloader/verify"
+)
+
+// bindOutput add flags of output file names
+func bindOutput(cf *configFlags) {
+	cf.bind("o", "output", "output file name template, fields:\n"+strings.TrimRight(outtmpl.Doc(), "\n")+"\n")
+	cf.bind("sanitize", "sanitize", "file name sanitizer profile: "+strings.Join(outtmpl.Profiles(), ", "))
+}
+
+// bindDanmaku add flags of danmaku style
+func bindDanmaku(cf *configFlags) {
+	cf.bind("danmaku-font", "danmaku.font", "font of danmaku")
+	cf.bind("danmaku-size", "danmaku.size", "font size of danmaku")
+	cf.bind("danmaku-alpha", "danmaku.alpha", "opacity of danmaku, 0-1")
+	cf.bind("danmaku-density", "danmaku.density", "share of screen height danmaku can use, 0-1")
+	cf.bind("danmaku-duration", "danmaku.duration", "seconds a scrolling danmaku stays on screen")
+	cf.bindBool("danmaku-no-top", "danmaku.no_top", "drop top danmaku")
+	cf.bindBool("danmaku-no-bottom", "danmaku.no_bottom", "drop bottom danmaku")
+	cf.bindBool("danmaku-overlap", "danmaku.overlap", "allow danmaku overlap instead of dropping them")
+}
+
+// progressWriter open json progress writer of fd for -progress json, nil for text progress bar
+func progressWriter(mode string, fd int) (*progress.JSONWriter, error) {
+	switch mode {
+	case "text":
+		return nil, nil
+	case "json":
+	default:
+		return nil, fmt.Errorf("unknown progress mode %q, text or json", mode)
+	}
+	if fd == 1 {
+		return progress.NewJSONWriter(os.Stdout), nil
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%v", fd))
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("progress fd %v not usable: %w", fd, err)
+	}
+	return progress.NewJSONWriter(f), nil
+}
+
+// connections return connections of all files, concurrency of a file if not set
+func connections(conf *config.Config) int {
+	switch {
+	case conf.Connections > 0:
+		return conf.Connections
+	case conf.Concurrency > 0:
+		return conf.Concurrency
+	}
+	return runtime.NumCPU()
+}
+
+// pageDownloader download pages to files named by config
+type pageDownloader struct {
+	conf      *config.Config
+	qn        int64
+	tmpl      *outtmpl.Template
+	sanitizer outtmpl.Sanitizer
+	limiter   *download.RateLimiter
+	extras    *extraOptions
+}
+
+// newPageDownloader create page downloader of validated config
+func newPageDownloader(conf *config.Config) *pageDownloader {
+	qn, _ := consts.ParseQuality(conf.Quality)
+	tmpl, _ := outtmpl.Parse(conf.Output)
+	sanitizer, _ := outtmpl.GetSanitizer(conf.Sanitize)
+	rate, _ := conf.RateLimitBytes()
+	return &pageDownloader{
+		conf:      conf,
+		qn:        qn,
+		tmpl:      tmpl,
+		sanitizer: sanitizer,
+		limiter:   download.NewRateLimiter(rate),
+		extras:    extrasOf(conf),
+	}
+}
+
+// pageFile is a page being downloaded to file
+type pageFile struct {
+	video *download.VideoInfo
+	info  *download.DownloadInfo
+	name  string
+	of    *os.File
+	d     *download.VideoDownloader
+}
+
+// prepare get playurl of video in quality qn, then create its file and downloader
+func (p *pageDownloader) prepare(video *download.VideoInfo, qn int64) (*pageFile, error) {
+	info, err := download.GetDownloadInfo(video, qn)
+	if err != nil {
+		return nil, err
+	}
+	fileName, err := p.tmpl.Prepare(outtmpl.FieldsOf(video, info), &outtmpl.Options{Sanitizer: p.sanitizer})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("start download file %v, quality %v, size %v bytes", fileName, consts.QualityName(info.Qn), info.Size)
+	of, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d := download.NewVideoDownloader(info, of).
+		SetConcurrency(p.conf.Concurrency).
+		SetRateLimiter(p.limiter)
+	return &pageFile{video: video, info: info, name: fileName, of: of, d: d}, nil
+}
+
+// finish close file of page with result of download, it's removed if failed or broken, or sidecar files are saved
+func (p *pageDownloader) finish(f *pageFile, err error) error {
+	if err != nil {
+		f.of.Close()
+		os.Remove(f.name)
+		return fmt.Errorf("download file %v error: %w", f.name, err)
+	}
+	f.of.Sync()
+	f.of.Close()
+	if !p.conf.NoVerify {
+		exp := &verify.Expect{Size: f.info.Size, MD5: f.info.MD5}
+		// formats not mapped to a file extension are detected from content
+		if f.info.Format == "flv" || f.info.Format == "mp4" {
+			exp.Format = f.info.Format
+		}
+		if _, err := verify.File(f.name, exp); err != nil {
+			os.Remove(f.name)
+			return fmt.Errorf("verify file %v error: %w", f.name, err)
+		}
+	}
+	log.Infof("download file %v success", f.name)
+
+	if p.extras.RemuxMP4 {
+		f.name = remuxMP4(f.name)
+	}
+	saveExtras(f.video, f.info, f.name, p.extras)
+	return nil
+}
+
+// loadBrowserCookies load cookies from browser if configured
+func loadBrowserCookies(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	cks, err := browser.Load(spec)
+	if err != nil {
+		return fmt.Errorf("load cookies from browser error: %w", err)
+	}
+	log.Infof("load %v cookies from %v", cookie.GetCookieJar().Add(cks), spec)
+	return nil
+}
+
+func runDownload(args []string) {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	tg := newTargetFlags(fs)
+	cf := newConfigFlags(fs)
+	cf.bind("q", "quality", "video quality, best, qn number or name like 1080P/4K")
+	cf.bind("codec", "codec", "video codec, only avc for flv/mp4 streams")
+	bindOutput(cf)
+	cf.bind("concurrency", "concurrency", "fragments of a file downloaded at the same time, 0 for cpu count")
+	cf.bind("jobs", "jobs", "files downloaded at the same time")
+	cf.bind("connections", "connections", "connections of all files downloaded at the same time, 0 for concurrency")
+	cf.bind("rate-limit", "rate_limit", "download speed limit in bytes per second like 2M, empty for no limit")
+	cf.bindBool("no-verify", "no_verify", "skip checking size, md5 and structure of downloaded files")
+	cf.bind("cookies-from-browser", "cookies_from_browser", "load bilibili cookies from browser[:profile], browser is one of "+
+		strings.Join(browser.Browsers(), ", "))
+	cf.bindProxy()
+	progressMode := fs.String("progress", "text", "progress output, text for a progress bar or json for json lines events")
+	progressFd := fs.Int("progress-fd", 1, "file descriptor json progress events are written to, 1 for stdout")
+	cf.bindBool("danmaku", "postprocess.danmaku", "download danmaku as ass subtitle")
+	bindDanmaku(cf)
+	cf.bind("subs", "postprocess.subs", "subtitle languages to download like zh-CN,en-US, all for every language")
+	cf.bind("subs-format", "postprocess.subs_format", "subtitle format, srt or vtt")
+	cf.bindBool("embed-subs", "postprocess.embed_subs", "embed downloaded subtitles into mp4 file, ffmpeg required")
+	cf.bindBool("write-info-json", "postprocess.write_info_json", "write metadata to .info.json sidecar")
+	cf.bindBool("write-nfo", "postprocess.write_nfo", "write .nfo sidecar for media servers")
+	cf.bindBool("embed-meta", "postprocess.embed_meta", "embed title, uploader, date, description and cover into mp4 file, ffmpeg required")
+	cf.bindBool("write-cover", "postprocess.write_cover", "save video cover image")
+	cf.bindBool("write-thumbnail", "postprocess.write_thumbnail", "save first frame of every page as thumbnail")
+	cf.bindBool("write-chapters", "postprocess.write_chapters", "save chapters as ffmetadata and plain text")
+	cf.bindBool("embed-chapters", "postprocess.embed_chapters", "embed chapters into mp4 file, ffmpeg required")
+	cf.bindBool("remux-mp4", "postprocess.remux_mp4", "remux flv files to mp4 without re-encoding, no ffmpeg required")
+	setUsage(fs, "download [flags] <id>...", "download videos with sidecar files")
+	fs.Parse(args)
+	ids := tg.ids()
+
+	conf, _ := cf.load()
+	pd := newPageDownloader(conf)
+
+	pw, err := progressWriter(*progressMode, *progressFd)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(2)
+	}
+	var reporter download.ProgressReporter
+	if pw == nil {
+		reporter = progress.NewReporter(os.Stdout)
+		if tty, ok := reporter.(*progress.TTY); ok {
+			// keep log lines off the bars
+			stdlog.SetOutput(tty.LogWriter(os.Stderr))
+		}
+	}
+	if err := loadBrowserCookies(conf.CookiesFromBrowser); err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
+
+	// playurl of the next page is fetched while files are downloading
+	sched := download.NewScheduler(conf.Jobs, connections(conf))
+	var failed int32
+	err = tg.each(ids, func(video *download.VideoInfo) error {
+		log.Infof("process avid %v, cid %v", video.Avid, video.Cid)
+		var job *progress.Job
+		if pw != nil {
+			job = pw.Job(video.VideoID, video.Page)
+		}
+		// errors after download started are reported by downloader
+		fail := func(err error) error {
+			if job != nil {
+				job.Fail(err)
+			}
+			return err
+		}
+		f, err := pd.prepare(video, pd.qn)
+		if err != nil {
+			return fail(err)
+		}
+		if job != nil {
+			job.File, job.Quality = f.name, consts.QualityName(f.info.Qn)
+			f.d.SetEventHandler(job.Handle)
+		} else {
+			f.d.SetProgressReporter(reporter)
+		}
+		sched.Submit(f.d, func(err error) {
+			if err := pd.finish(f, err); err != nil {
+				log.Errorf("process %v page %v error: %v", video.VideoID, video.Page, err)
+				atomic.StoreInt32(&failed, 1)
+			}
+		})
+		return nil
+	})
+	sched.Wait()
+	cookie.SaveCookies()
+	if err != nil || atomic.LoadInt32(&failed) != 0 {
+		os.Exit(1)
+	}
+	log.Infof("download %v success", strings.Join(ids, ", "))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/config"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/outtmpl"
+)
+
+// sidecarName return file name of page from output template without downloading video, ext is given
+func sidecarName(conf *config.Config, video *download.VideoInfo, ext string) (string, error) {
+	tmpl, _ := outtmpl.Parse(conf.Output)
+	sanitizer, _ := outtmpl.GetSanitizer(conf.Sanitize)
+	fields := outtmpl.FieldsOf(video, nil)
+	fields["ext"] = ext
+	return tmpl.Prepare(fields, &outtmpl.Options{Sanitizer: sanitizer})
+}
+
+func runDanmaku(args []string) {
+	fs := flag.NewFlagSet("danmaku", flag.ExitOnError)
+	tg := newTargetFlags(fs)
+	cf := newConfigFlags(fs)
+	bindOutput(cf)
+	bindDanmaku(cf)
+	cf.bindProxy()
+	setUsage(fs, "danmaku [flags] <id>...", "download danmaku of videos as ass subtitles, named by output template with ext ass")
+	fs.Parse(args)
+	ids := tg.ids()
+	conf, _ := cf.load()
+
+	err := tg.each(ids, func(video *download.VideoInfo) error {
+		fileName, err := sidecarName(conf, video, "ass")
+		if err != nil {
+			return err
+		}
+		if err := saveDanmaku(video.Cid, fileName, conf.DanmakuOptions()); err != nil {
+			return err
+		}
+		log.Infof("save danmaku %v success", fileName)
+		return nil
+	})
+	cookie.SaveCookies()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func runSubs(args []string) {
+	fs := flag.NewFlagSet("subs", flag.ExitOnError)
+	tg := newTargetFlags(fs)
+	cf := newConfigFlags(fs)
+	bindOutput(cf)
+	cf.bind("subs", "postprocess.subs", "subtitle languages to download like zh-CN,en-US, all if empty")
+	cf.bind("subs-format", "postprocess.subs_format", "subtitle format, srt or vtt")
+	cf.bindProxy()
+	setUsage(fs, "subs [flags] <id>...", "download cc subtitles of videos, named by output template with language and format as ext")
+	fs.Parse(args)
+	ids := tg.ids()
+	conf, _ := cf.load()
+
+	lans := conf.PostProcess.Subs
+	if lans == "" {
+		lans = "all"
+	}
+	format := conf.PostProcess.SubsFormat
+	err := tg.each(ids, func(video *download.VideoInfo) error {
+		fileName, err := sidecarName(conf, video, format)
+		if err != nil {
+			return err
+		}
+		player, err := download.GetPlayerInfo(video.Avid, video.Cid)
+		if err != nil {
+			return err
+		}
+		saveSubtitles(player, strings.TrimSuffix(fileName, filepath.Ext(fileName)), lans, format)
+		return nil
+	})
+	cookie.SaveCookies()
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/meta"
+)
+
+func runFormats(args []string) {
+	fs := flag.NewFlagSet("formats", flag.ExitOnError)
+	tg := newTargetFlags(fs)
+	cf := newConfigFlags(fs)
+	cf.bindProxy()
+	setUsage(fs, "formats [flags] <id>...", "list qualities of every page, * marks the one current account gets")
+	fs.Parse(args)
+	ids := tg.ids()
+	cf.load()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	err := tg.each(ids, func(video *download.VideoInfo) error {
+		info, err := download.GetDownloadInfo(video, consts.QnBest)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v p%v %v\n", video.VideoID, video.Page, video.PartName)
+		fmt.Fprintf(w, "\tQN\tQUALITY\tNEED\tFORMAT\tSIZE\n")
+		for _, s := range meta.ListStreams(info) {
+			mark, size := "", ""
+			if s.Selected {
+				mark, size = "*", consts.Byte(s.Size).String()
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", mark, s.Qn, s.Quality, s.Need, s.Format, size)
+		}
+		return w.Flush()
+	})
+	cookie.SaveCookies()
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/meta"
+)
+
+func runInfo(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	tg := newTargetFlags(fs)
+	var (
+		format  string
+		streams bool
+	)
+	fs.StringVar(&format, "format", "table", "output format, table, json or yaml")
+	fs.BoolVar(&streams, "streams", true, "query qualities of every page, one playurl request per page")
+	cf := newConfigFlags(fs)
+	cf.bindProxy()
+	setUsage(fs, "info [flags] <id>...", "print videos, pages and qualities without downloading, schema of json and yaml is in README")
+	fs.Parse(args)
+	ids := tg.ids()
+	if format != "table" && format != "json" && format != "yaml" {
+		log.Errorf("unsupported format %v", format)
+		os.Exit(2)
+	}
+	cf.load()
+
+	pageMatch, _ := parsePages(tg.pages)
+	listing := &meta.Listing{Version: meta.ListingVersion, Videos: []*meta.ListingVideo{}}
+	failed := false
+	for _, id := range ids {
+		videos, err := download.GetVideoInfosById(id)
+		if err != nil {
+			log.Errorf("get videos of %v error: %v", id, err)
+			failed = true
+			continue
+		}
+		var (
+			matched []*download.VideoInfo
+			infos   []*download.DownloadInfo
+			errs    []error
+		)
+		for _, video := range videos {
+			if !pageMatch(video.Page) {
+				continue
+			}
+			matched = append(matched, video)
+			if !streams {
+				continue
+			}
+			info, err := download.GetDownloadInfo(video, consts.QnBest)
+			if err != nil {
+				log.Warnf("get qualities of %v page %v error: %v", id, video.Page, err)
+			}
+			infos = append(infos, info)
+			errs = append(errs, err)
+		}
+		listing.Videos = append(listing.Videos, meta.ListVideo(id, matched, infos, errs))
+	}
+	cookie.SaveCookies()
+
+	var err error
+	switch format {
+	case "json":
+		err = listing.WriteJSON(os.Stdout)
+	case "yaml":
+		err = listing.WriteYAML(os.Stdout)
+	default:
+		err = listing.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		log.Errorf("write info error: %v", err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/download/passport"
+	"rsc.io/qr"
+)
+
+func runLogin(args []string) {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	var (
+		invert  bool
+		timeout time.Duration
+	)
+	fs.BoolVar(&invert, "invert", false, "invert qrcode colors, for terminals with light background")
+	fs.DurationVar(&timeout, "timeout", 3*time.Minute, "give up if not confirmed in time")
+	cf := newConfigFlags(fs)
+	cf.bindProxy()
+	setUsage(fs, "login [flags]", "login by scanning qrcode with bilibili app, cookies are saved to profile")
+	fs.Parse(args)
+	cf.load()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	err := passport.LoginByQRCode(ctx, func(qrUrl string) {
+		if err := renderQR(os.Stdout, qrUrl, invert); err != nil {
+			log.Errorf("render qrcode error: %v", err)
+		}
+		fmt.Printf("scan the qrcode with bilibili app, or open %v on your phone\n", qrUrl)
+	}, func(status passport.QRStatus) {
+		log.Infof("qrcode %v", status)
+	})
+	if err != nil {
+		log.Errorf("login error: %v", err)
+		os.Exit(1)
+	}
+	cookie.SaveCookies()
+	log.Infof("login success")
+}
+
+// renderQR print qrcode with unicode half blocks, two rows of modules per line
+func renderQR(w io.Writer, text string, invert bool) error {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return err
+	}
+	const quiet = 2
+	// light modules are drawn, terminals are dark by default
+	light := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+			return !invert
+		}
+		return code.Black(x, y) == invert
+	}
+
+	sb := &strings.Builder{}
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		for x := -quiet; x < code.Size+quiet; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -9,64 +10,189 @@ import (
 
 	"github.com/apex/log"
 	"github.com/rammiah/bili-downloader/download"
-	"github.com/rammiah/bili-downloader/download/cookie"
 )
 
-func main() {
-	defer cookie.SaveCookies()
-	var (
-		id      string
-		pageStr string
-	)
-	flag.StringVar(&id, "id", "", "video id like avxxx/BVxxx")
-	flag.StringVar(&pageStr, "p", "", "page to download")
-	flag.Parse()
-	id = strings.TrimSpace(id)
-	if id == "" {
-		flag.Usage()
-		os.Exit(-1)
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands of bilidown, help is handled by main
+var commands = []*command{
+	{"download", "download videos with danmaku, subtitles and metadata", runDownload},
+	{"info", "print videos, pages and qualities as table, json or yaml", runInfo},
+	{"formats", "list qualities of videos", runFormats},
+	{"danmaku", "download danmaku as ass subtitles only", runDanmaku},
+	{"subs", "download cc subtitles only", runSubs},
+	{"login", "login by scanning qrcode with bilibili app", runLogin},
+	{"whoami", "show login state and vip of account", runWhoami},
+	{"cookies", "import or export cookies", runCookies},
+	{"profile", "manage account profiles", runProfile},
+	{"config", "show effective config", runConfig},
+	{"serve", "run web ui and http api to queue downloads", runServe},
+	{"verify", "check downloaded files for truncation and corruption", runVerify},
+}
+
+// globals flags given before command, used as defaults of command flags
+var globals struct {
+	verbose bool
+	quiet   bool
+	profile string
+	config  string
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
 	}
+	return nil
+}
 
-	pageMatch, err := parsePages(pageStr)
-	if err != nil {
-		log.Errorf("parse page matcher error: %v", err)
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: bilidown [global flags] <command> [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10v %v\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "  %-10v %v\n", "help", "show help of command")
+	fmt.Fprintf(out, "\nglobal flags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nrun `bilidown help <command>` for flags of command\n")
+}
+
+// setUsage set usage of command flags
+func setUsage(fs *flag.FlagSet, synopsis, summary string) {
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: bilidown %v\n%v\n\nflags:\n", synopsis, summary)
+		fs.PrintDefaults()
+	}
+}
+
+// isLegacy report if args are of old versions like `-id BVxxx -p 1`, which has -id flag but no command before it
+func isLegacy(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" || findCommand(arg) != nil || arg == "help" {
+			return false
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.HasPrefix(arg, "-") && (name == "id" || strings.HasPrefix(name, "id=")) {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	args := os.Args[1:]
+	if isLegacy(args) {
+		runDownload(args)
 		return
 	}
 
-	infos, err := download.GetVideoInfosById(id)
-	if err != nil {
-		panic(err)
+	flag.BoolVar(&globals.verbose, "v", false, "verbose, print debug logs")
+	flag.BoolVar(&globals.quiet, "quiet", false, "print warnings and errors only")
+	flag.StringVar(&globals.profile, "profile", "", "account profile, same as -profile of commands")
+	flag.StringVar(&globals.config, "config", "", "config file, same as -config of commands")
+	flag.Usage = usage
+	flag.Parse()
+
+	switch {
+	case globals.verbose:
+		log.SetLevel(log.DebugLevel)
+	case globals.quiet:
+		log.SetLevel(log.WarnLevel)
 	}
-	// filter video infos
-	// fmt.Printf("%v\n", utils.Json(resp))
-	replacer := strings.NewReplacer("/", " ", "|", " ")
-	for _, video := range infos {
-		if !pageMatch(video.Page) {
-			continue
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name, cmdArgs := flag.Arg(0), flag.Args()[1:]
+	if name == "help" {
+		if len(cmdArgs) == 0 {
+			usage()
+			return
 		}
-		log.Infof("process avid %v, cid %v", video.Avid, video.Cid)
-		info, err := download.GetDownloadInfoByAidCid(id, video.Avid, video.Cid)
+		name, cmdArgs = cmdArgs[0], []string{"-h"}
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	cmd.run(cmdArgs)
+}
+
+// targetFlags video ids and pages of a command, ids are args of command
+type targetFlags struct {
+	fs    *flag.FlagSet
+	id    string
+	pages string
+}
+
+func newTargetFlags(fs *flag.FlagSet) *targetFlags {
+	t := &targetFlags{fs: fs}
+	fs.StringVar(&t.id, "id", "", "video id like avxxx/BVxxx, bangumi id like epxxx/ssxxx/mdxxx or url of their page, same as args")
+	fs.StringVar(&t.pages, "p", "", "pages to process like 1,3-5, episode no for bangumi, all pages if empty")
+	return t
+}
+
+// ids return ids given by -id and args, exit if none
+func (t *targetFlags) ids() []string {
+	var ids []string
+	for _, arg := range append([]string{t.id}, t.fs.Args()...) {
+		id, err := download.ParseId(arg)
 		if err != nil {
-			panic(err)
+			log.Errorf("%v", err)
+			os.Exit(2)
 		}
-		fileName := video.Title + " - " + video.PartName + "." + info.Format
-		fileName = replacer.Replace(fileName)
-		log.Infof("start download file %v, size %v bytes", fileName, info.Size)
-		of, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		t.fs.Usage()
+		os.Exit(2)
+	}
+	if _, err := parsePages(t.pages); err != nil {
+		log.Errorf("parse page matcher error: %v", err)
+		os.Exit(2)
+	}
+	return ids
+}
+
+// errFailed returned by each if any video failed, errors are logged already
+var errFailed = errors.New("some videos failed")
+
+// each call fn with every matched page of ids, errors are logged and the rest are still processed
+func (t *targetFlags) each(ids []string, fn func(video *download.VideoInfo) error) error {
+	pageMatch, _ := parsePages(t.pages)
+	failed := false
+	for _, id := range ids {
+		videos, err := download.GetVideoInfosById(id)
 		if err != nil {
-			panic(err)
+			log.Errorf("get videos of %v error: %v", id, err)
+			failed = true
+			continue
 		}
-		if err := download.NewVideoDownloader(info, of).Download(); err != nil {
-			log.Infof("download file error: %v", err)
-			of.Close()
-			os.Remove(fileName)
-			panic(err)
+		for _, video := range videos {
+			if !pageMatch(video.Page) {
+				continue
+			}
+			if err := fn(video); err != nil {
+				log.Errorf("process %v page %v error: %v", id, video.Page, err)
+				failed = true
+			}
 		}
-		log.Infof("download file %v success", fileName)
-		of.Sync()
-		of.Close()
 	}
-	log.Infof("download %v success", id)
+	if failed {
+		return errFailed
+	}
+	return nil
 }
 
 // 前闭后开区间
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLegacy(t *testing.T) {
+	for _, args := range [][]string{
+		{"-id", "BV1pP4y1b7iP"},
+		{"-o", "{title}.{ext}", "--id=BV1pP4y1b7iP", "-p", "1"},
+		{"-danmaku", "-id", "ep123"},
+	} {
+		require.True(t, isLegacy(args), args)
+	}
+	for _, args := range [][]string{
+		nil,
+		{"download", "-id", "BV1pP4y1b7iP"},
+		{"-v", "download", "BV1pP4y1b7iP"},
+		{"help", "download"},
+		{"-profile", "premium", "whoami"},
+	} {
+		require.False(t, isLegacy(args), args)
+	}
+}
+
+func TestParsePages(t *testing.T) {
+	match, err := parsePages("1,3-5")
+	require.Nil(t, err)
+	for page, ok := range map[int64]bool{1: true, 2: false, 3: true, 5: true, 6: false} {
+		require.Equal(t, ok, match(page), page)
+	}
+	match, err = parsePages("")
+	require.Nil(t, err)
+	require.True(t, match(100))
+	_, err = parsePages("1-x")
+	require.NotNil(t, err)
+}
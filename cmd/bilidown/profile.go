@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/profile"
+)
+
+func runProfile(args []string) {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: bilidown profile list\n")
+		fmt.Fprintf(fs.Output(), "       bilidown profile add <name>\n")
+		fmt.Fprintf(fs.Output(), "       bilidown profile remove <name>\n")
+		fmt.Fprintf(fs.Output(), "       bilidown profile use <name>\n")
+		fmt.Fprintf(fs.Output(), "cookies and config.toml of a profile are kept in %v\n", profile.Dir("<name>"))
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cmd := args[0]
+	fs.Parse(args[1:])
+	if (cmd == "list" && fs.NArg() != 0) || (cmd != "list" && fs.NArg() != 1) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	name := fs.Arg(0)
+
+	switch cmd {
+	case "list":
+		names, err := profile.List()
+		if err != nil {
+			log.Errorf("list profiles error: %v", err)
+			os.Exit(1)
+		}
+		current := profile.Current()
+		for _, n := range names {
+			mark := " "
+			if n == current {
+				mark = "*"
+			}
+			fmt.Printf("%v %v\n", mark, n)
+		}
+	case "add":
+		if err := profile.Add(name); err != nil {
+			log.Errorf("add profile %v error: %v", name, err)
+			os.Exit(1)
+		}
+		log.Infof("profile %v created, login with `bilidown login -profile %v`", name, name)
+	case "remove":
+		if err := profile.Remove(name); err != nil {
+			log.Errorf("remove profile %v error: %v", name, err)
+			os.Exit(1)
+		}
+		log.Infof("profile %v removed", name)
+	case "use":
+		if err := profile.SetCurrent(name); err != nil {
+			log.Errorf("use profile %v error: %v", name, err)
+			os.Exit(1)
+		}
+		log.Infof("switch to profile %v", name)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/config"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/meta"
+	"github.com/rammiah/bili-downloader/server"
+)
+
+// stateFile is file jobs of server are saved to, in directory of profile
+const stateFile = "jobs.json"
+
+// serveRunner download pages of server jobs like download command
+type serveRunner struct {
+	pd    *pageDownloader
+	conns *download.ConnPool
+}
+
+func (r *serveRunner) Info(videoID string) (*meta.ListingVideo, error) {
+	videos, err := download.GetVideoInfosById(videoID)
+	if err != nil {
+		return nil, err
+	}
+	// qualities rarely differ between pages, a playurl request for every page is slow
+	var (
+		infos []*download.DownloadInfo
+		errs  []error
+	)
+	if len(videos) > 0 {
+		info, err := download.GetDownloadInfo(videos[0], consts.QnBest)
+		infos, errs = []*download.DownloadInfo{info}, []error{err}
+	}
+	return meta.ListVideo(videoID, videos, infos, errs), nil
+}
+
+func (r *serveRunner) Resolve(videoID, pages string) ([]*download.VideoInfo, error) {
+	pageMatch, err := parsePages(pages)
+	if err != nil {
+		return nil, err
+	}
+	videos, err := download.GetVideoInfosById(videoID)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*download.VideoInfo, 0, len(videos))
+	for _, video := range videos {
+		if pageMatch(video.Page) {
+			matched = append(matched, video)
+		}
+	}
+	return matched, nil
+}
+
+func (r *serveRunner) Download(ctx context.Context, video *download.VideoInfo, quality string,
+	start func(string, *download.VideoDownloader)) error {
+	qn := r.pd.qn
+	if quality != "" {
+		var err error
+		if qn, err = consts.ParseQuality(quality); err != nil {
+			return err
+		}
+	}
+	f, err := r.pd.prepare(video, qn)
+	if err != nil {
+		return err
+	}
+	start(f.name, f.d)
+	err = f.d.SetContext(ctx).SetConnPool(r.conns).Download()
+	return r.pd.finish(f, err)
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address http api listens on")
+	state := fs.String("state", "", "file jobs are saved to, "+stateFile+" in directory of profile if empty")
+	token := fs.String("token", "", "token required by api and metrics, web ui is opened by /?token=<token> once, env "+config.EnvPrefix+"TOKEN")
+	cf := newConfigFlags(fs)
+	cf.bind("q", "quality", "video quality, best, qn number or name like 1080P/4K")
+	bindOutput(cf)
+	cf.bind("concurrency", "concurrency", "fragments of a file downloaded at the same time, 0 for cpu count")
+	cf.bind("jobs", "jobs", "jobs downloaded at the same time")
+	cf.bind("connections", "connections", "connections of all jobs downloaded at the same time, 0 for concurrency")
+	cf.bind("rate-limit", "rate_limit", "download speed limit in bytes per second like 2M, empty for no limit")
+	cf.bindBool("no-verify", "no_verify", "skip checking size, md5 and structure of downloaded files")
+	cf.bindProxy()
+	setUsage(fs, "serve [flags]", "run web ui and http api to queue and control downloads, jobs are kept across restarts")
+	fs.Parse(args)
+	if *token == "" {
+		*token = os.Getenv(config.EnvPrefix + "TOKEN")
+	}
+
+	conf, _ := cf.load()
+	if err := loadBrowserCookies(conf.CookiesFromBrowser); err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
+	if *state == "" {
+		*state = filepath.Join(cookie.StoreDir(), stateFile)
+	}
+	runner := &serveRunner{pd: newPageDownloader(conf), conns: download.NewConnPool(connections(conf))}
+	m, err := server.NewManager(runner, server.NewStore(*state), conf.Jobs)
+	if err != nil {
+		log.Errorf("load jobs error: %v", err)
+		os.Exit(1)
+	}
+	m.Start()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: server.NewHandler(m, *token),
+		// event streams end when shutting down
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		log.Infof("shutting down")
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+
+	log.Infof("serving on http://%v, jobs are saved to %v", *addr, *state)
+	if *token != "" {
+		log.Infof("open http://%v/?token=%v to use web ui", *addr, *token)
+	} else if host, _, _ := net.SplitHostPort(*addr); host != "localhost" && !net.ParseIP(host).IsLoopback() {
+		log.Warnf("api has no token but listens on %v, only ip addresses are accepted as host, use -token to allow host names", *addr)
+	}
+	err = srv.ListenAndServe()
+	m.Close()
+	cookie.SaveCookies()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("serve error: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/config"
+	"github.com/rammiah/bili-downloader/danmaku"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/meta"
+	"github.com/rammiah/bili-downloader/postproc"
+	"github.com/rammiah/bili-downloader/subtitle"
+)
+
+// extraOptions control files saved alongside the video
+type extraOptions struct {
+	Danmaku       bool
+	DanmakuOpts   danmaku.Options
+	SubLans       string
+	SubFormat     string
+	EmbedSubs     bool
+	InfoJSON      bool
+	NFO           bool
+	EmbedMeta     bool
+	Cover         bool
+	Thumbnail     bool
+	Chapters      bool
+	EmbedChapters bool
+	RemuxMP4      bool
+}
+
+// extrasOf build extra options from config
+func extrasOf(conf *config.Config) *extraOptions {
+	pp := conf.PostProcess
+	return &extraOptions{
+		Danmaku:       pp.Danmaku,
+		DanmakuOpts:   conf.DanmakuOptions(),
+		SubLans:       pp.Subs,
+		SubFormat:     pp.SubsFormat,
+		EmbedSubs:     pp.EmbedSubs,
+		InfoJSON:      pp.WriteInfoJSON,
+		NFO:           pp.WriteNFO,
+		EmbedMeta:     pp.EmbedMeta,
+		Cover:         pp.WriteCover,
+		Thumbnail:     pp.WriteThumbnail,
+		Chapters:      pp.WriteChapters,
+		EmbedChapters: pp.EmbedChapters,
+		RemuxMP4:      pp.RemuxMP4,
+	}
+}
+
+// skipEmbedOnce warn only once about files not embeddable in a run
+var skipEmbedOnce sync.Once
+
+// saveExtras save sidecar files of a downloaded page and embed them into video,
+// errors are logged only as video itself is downloaded
+func saveExtras(video *download.VideoInfo, info *download.DownloadInfo, fileName string, opts *extraOptions) {
+	var (
+		base      = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		embedOpts = &postproc.EmbedOptions{}
+		tmpFiles  []string
+		player    *download.PlayerInfo
+	)
+	defer func() {
+		for _, f := range tmpFiles {
+			os.Remove(f)
+		}
+	}()
+
+	if (opts.EmbedSubs || opts.EmbedMeta || opts.EmbedChapters) && !strings.EqualFold(filepath.Ext(fileName), ".mp4") {
+		skipEmbedOnce.Do(func() {
+			log.Warnf("embedding is skipped for %v files as only mp4 is supported, use -remux-mp4 for flv", filepath.Ext(fileName))
+		})
+		noEmbed := *opts
+		noEmbed.EmbedSubs, noEmbed.EmbedMeta, noEmbed.EmbedChapters = false, false, false
+		opts = &noEmbed
+	}
+
+	if opts.SubLans != "" || opts.Chapters || opts.EmbedChapters {
+		var err error
+		if player, err = download.GetPlayerInfo(video.Avid, video.Cid); err != nil {
+			log.Errorf("get player info of cid %v error: %v", video.Cid, err)
+		}
+	}
+
+	if opts.Danmaku {
+		assName := base + ".ass"
+		if err := saveDanmaku(video.Cid, assName, opts.DanmakuOpts); err != nil {
+			log.Errorf("save danmaku of cid %v error: %v", video.Cid, err)
+		} else {
+			log.Infof("save danmaku %v success", assName)
+		}
+	}
+
+	if opts.SubLans != "" && player != nil {
+		subs := saveSubtitles(player, base, opts.SubLans, opts.SubFormat)
+		if opts.EmbedSubs {
+			embedOpts.Subtitles = subs
+		}
+	}
+
+	if opts.InfoJSON {
+		if err := saveInfoJSON(video, info, base+".info.json"); err != nil {
+			log.Errorf("save info json error: %v", err)
+		}
+	}
+	if opts.NFO {
+		if err := saveNFO(video, base+".nfo"); err != nil {
+			log.Errorf("save nfo error: %v", err)
+		}
+	}
+
+	if video.Meta != nil && video.Meta.Cover != "" && (opts.Cover || opts.EmbedMeta) {
+		cover := base + ".cover" + imageExt(video.Meta.Cover)
+		if err := saveImage(video.Meta.Cover, cover); err != nil {
+			log.Errorf("save cover error: %v", err)
+		} else {
+			embedOpts.Cover = cover
+			if !opts.Cover {
+				tmpFiles = append(tmpFiles, cover)
+			}
+		}
+	}
+	if opts.EmbedMeta {
+		embedOpts.Metadata = meta.MP4Tags(video)
+	} else {
+		embedOpts.Cover = ""
+	}
+
+	if opts.Thumbnail && video.FirstFrame != "" {
+		thumb := base + ".thumb" + imageExt(video.FirstFrame)
+		if err := saveImage(video.FirstFrame, thumb); err != nil {
+			log.Errorf("save thumbnail error: %v", err)
+		}
+	}
+
+	if (opts.Chapters || opts.EmbedChapters) && player != nil && len(player.ViewPoints) != 0 {
+		chapters := meta.Chapters(video, player.ViewPoints)
+		ffmeta := base + ".ffmetadata"
+		if err := os.WriteFile(ffmeta, []byte(meta.FFMetadata(video, chapters)), 0644); err != nil {
+			log.Errorf("save chapters error: %v", err)
+		} else if opts.EmbedChapters {
+			embedOpts.Chapters = ffmeta
+		}
+		if opts.Chapters {
+			if err := os.WriteFile(base+".chapters.txt", []byte(meta.ChapterText(chapters)), 0644); err != nil {
+				log.Errorf("save chapters error: %v", err)
+			}
+		} else {
+			tmpFiles = append(tmpFiles, ffmeta)
+		}
+	}
+
+	if err := postproc.Embed(fileName, embedOpts); err != nil {
+		log.Errorf("embed into %v error: %v", fileName, err)
+	}
+}
+
+func saveDanmaku(cid int64, fileName string, opts danmaku.Options) error {
+	buf, err := download.GetDanmakuXML(cid)
+	if err != nil {
+		return err
+	}
+	of, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+
+	return danmaku.ConvertXML(bytes.NewReader(buf), of, opts)
+}
+
+// matchLan check if subtitle language is selected, zh selects zh-CN and ai-zh
+func matchLan(lan string, selected []string) bool {
+	for _, sel := range selected {
+		switch {
+		case sel == "all" || sel == "*":
+			return true
+		case strings.EqualFold(sel, lan):
+			return true
+		case strings.HasPrefix(strings.ToLower(lan), strings.ToLower(sel)+"-"):
+			return true
+		case strings.EqualFold("ai-"+sel, lan):
+			return true
+		}
+	}
+	return false
+}
+
+// saveSubtitles save selected subtitles as base.lan.format, saved files are returned for embedding
+func saveSubtitles(player *download.PlayerInfo, base, lans, format string) []*postproc.Subtitle {
+	if len(player.Subtitles) == 0 {
+		log.Infof("no subtitle found for cid %v", player.Cid)
+		return nil
+	}
+
+	var (
+		selected = strings.Split(lans, ",")
+		saved    []*postproc.Subtitle
+	)
+	for _, sub := range player.Subtitles {
+		if !matchLan(sub.Lan, selected) {
+			continue
+		}
+		fileName := fmt.Sprintf("%v.%v.%v", base, sub.Lan, format)
+		if err := saveSubtitle(sub, fileName, format); err != nil {
+			log.Errorf("save subtitle %v error: %v", sub.Lan, err)
+			continue
+		}
+		log.Infof("save subtitle %v success", fileName)
+		saved = append(saved, &postproc.Subtitle{
+			File:  fileName,
+			Lang:  subtitle.ISO639(sub.Lan),
+			Title: sub.LanDoc,
+		})
+	}
+	return saved
+}
+
+func saveSubtitle(sub *download.SubtitleInfo, fileName, format string) error {
+	buf, err := download.GetSubtitle(sub)
+	if err != nil {
+		return err
+	}
+	bcc, err := subtitle.ParseBCC(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	of, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+
+	return subtitle.Write(of, bcc.Body, format)
+}
+
+func saveInfoJSON(video *download.VideoInfo, info *download.DownloadInfo, fileName string) error {
+	buf, err := meta.InfoJSON(video, info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, buf, 0644)
+}
+
+func saveNFO(video *download.VideoInfo, fileName string) error {
+	buf, err := meta.NFO(video)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, buf, 0644)
+}
+
+func saveImage(u, fileName string) error {
+	buf, err := download.GetImage(u)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, buf, 0644)
+}
+
+// imageExt return extension of image url, .jpg by default
+func imageExt(u string) string {
+	if idx := strings.IndexAny(u, "?@"); idx != -1 {
+		u = u[:idx]
+	}
+	switch ext := strings.ToLower(path.Ext(u)); ext {
+	case ".jpg", ".jpeg", ".png", ".webp", ".gif":
+		return ext
+	}
+	return ".jpg"
+}
+
+// remuxMP4 remux flv file to mp4 and remove it, name of the file kept is returned,
+// flv is kept if remux failed as it's still playable
+func remuxMP4(fileName string) string {
+	if !strings.EqualFold(filepath.Ext(fileName), ".flv") {
+		return fileName
+	}
+	mp4Name := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".mp4"
+	if err := postproc.RemuxFLV(fileName, mp4Name); err != nil {
+		log.Errorf("remux %v to mp4 error: %v", fileName, err)
+		return fileName
+	}
+	os.Remove(fileName)
+	log.Infof("remux %v to %v success", fileName, mp4Name)
+	return mp4Name
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/verify"
+)
+
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	exp := &verify.Expect{}
+	fs.Int64Var(&exp.Size, "size", 0, "expected size in bytes, only for a single file")
+	fs.StringVar(&exp.MD5, "md5", "", "expected md5 in hex, only for a single file")
+	fs.StringVar(&exp.Format, "format", "", "expected format, flv or mp4, detected from content if empty")
+	setUsage(fs, "verify [flags] <file>...", "check downloaded files for truncation, zeroed fragments and broken flv tags or mp4 boxes")
+	fs.Parse(args)
+	files := fs.Args()
+	if len(files) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if len(files) > 1 && (exp.Size != 0 || exp.MD5 != "") {
+		log.Errorf("-size and -md5 are only for a single file")
+		os.Exit(2)
+	}
+	if exp.Format != "" && exp.Format != "flv" && exp.Format != "mp4" {
+		log.Errorf("unsupported format %v", exp.Format)
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range files {
+		r, err := verify.File(name, exp)
+		if err != nil {
+			fmt.Printf("FAIL  %v: %v\n", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("ok    %v: %v\n", name, describe(r))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// describe summary report of a valid file
+func describe(r *verify.Report) string {
+	parts := []string{r.Format, consts.Byte(r.Size).String()}
+	if r.Format == "flv" {
+		parts = append(parts, fmt.Sprintf("%v tags", r.Tags))
+	} else {
+		parts = append(parts, "boxes "+strings.Join(r.Boxes, " "))
+	}
+	parts = append(parts, r.Duration.Round(time.Millisecond).String(), "md5 "+r.MD5)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+)
+
+func runWhoami(args []string) {
+	fs := flag.NewFlagSet("whoami", flag.ExitOnError)
+	cf := newConfigFlags(fs)
+	cf.bindProxy()
+	setUsage(fs, "whoami [flags]", "show login state, vip and best quality of account")
+	fs.Parse(args)
+	cf.load()
+
+	acc, err := download.GetAccount()
+	if err != nil {
+		log.Errorf("get account error: %v", err)
+		os.Exit(1)
+	}
+	if !acc.IsLogin {
+		fmt.Printf("not logged in, best quality %v\n", consts.QualityName(acc.MaxQuality()))
+		return
+	}
+	fmt.Printf("name:        %v\n", acc.Name)
+	fmt.Printf("mid:         %v\n", acc.Mid)
+	fmt.Printf("vip:         %v\n", vipText(acc))
+	fmt.Printf("max quality: %v\n", consts.QualityName(acc.MaxQuality()))
+}
+
+func vipText(acc *download.Account) string {
+	if !acc.IsVIP() {
+		if acc.VipType != download.VipNone && !acc.VipDueDate.IsZero() {
+			return "expired at " + acc.VipDueDate.Format("2006-01-02")
+		}
+		return "none"
+	}
+	name := acc.VipLabel
+	if name == "" {
+		name = "monthly"
+		if acc.VipType == download.VipAnnual {
+			name = "annual"
+		}
+	}
+	return fmt.Sprintf("%v, expires at %v", name, acc.VipDueDate.Format("2006-01-02"))
+}
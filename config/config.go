@@ -0,0 +1,377 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/danmaku"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/outtmpl"
+)
+
+const (
+	FileName  = "config.toml"
+	EnvPrefix = "BILIDOWN_"
+)
+
+// Config defaults of command line, keys are toml keys joined by dot like proxy.api,
+// values are from flags, env vars and config files, the former wins
+type Config struct {
+	Output             string `toml:"output"`
+	Sanitize           string `toml:"sanitize"`
+	Quality            string `toml:"quality"`
+	Codec              string `toml:"codec"`
+	Concurrency        int    `toml:"concurrency"` // fragments downloaded at the same time, 0 for cpu count
+	Jobs               int    `toml:"jobs"`        // files downloaded at the same time
+	Connections        int    `toml:"connections"` // connections of all files, 0 for concurrency
+	RateLimit          string `toml:"rate_limit"`  // bytes per second like 2M, empty for no limit
+	NoVerify           bool   `toml:"no_verify"`   // skip checking size, md5 and structure of downloaded files
+	CookiesFromBrowser string `toml:"cookies_from_browser"`
+
+	Proxy       Proxy       `toml:"proxy"`
+	PostProcess PostProcess `toml:"postprocess"`
+	Danmaku     Danmaku     `toml:"danmaku"`
+}
+
+type Proxy struct {
+	Default string `toml:"default"`
+	API     string `toml:"api"`
+	CDN     string `toml:"cdn"`
+}
+
+type PostProcess struct {
+	Danmaku        bool   `toml:"danmaku"`
+	Subs           string `toml:"subs"`
+	SubsFormat     string `toml:"subs_format"`
+	EmbedSubs      bool   `toml:"embed_subs"`
+	WriteInfoJSON  bool   `toml:"write_info_json"`
+	WriteNFO       bool   `toml:"write_nfo"`
+	EmbedMeta      bool   `toml:"embed_meta"`
+	WriteCover     bool   `toml:"write_cover"`
+	WriteThumbnail bool   `toml:"write_thumbnail"`
+	WriteChapters  bool   `toml:"write_chapters"`
+	EmbedChapters  bool   `toml:"embed_chapters"`
+	RemuxMP4       bool   `toml:"remux_mp4"` // remux flv to mp4 without re-encoding
+}
+
+type Danmaku struct {
+	Font     string  `toml:"font"`
+	Size     float64 `toml:"size"`
+	Alpha    float64 `toml:"alpha"`
+	Density  float64 `toml:"density"`
+	Duration float64 `toml:"duration"`
+	NoTop    bool    `toml:"no_top"`
+	NoBottom bool    `toml:"no_bottom"`
+	Overlap  bool    `toml:"overlap"`
+}
+
+// Defaults return config used when nothing configured
+func Defaults() *Config {
+	dm := danmaku.DefaultOptions()
+	return &Config{
+		Output:   outtmpl.DefaultTemplate,
+		Sanitize: "windows",
+		Quality:  "best",
+		Codec:    "avc",
+		Jobs:     2,
+		PostProcess: PostProcess{
+			SubsFormat: "srt",
+		},
+		Danmaku: Danmaku{
+			Font:     dm.Font,
+			Size:     dm.FontSize,
+			Alpha:    dm.Alpha,
+			Density:  dm.Density,
+			Duration: dm.ScrollDuration,
+		},
+	}
+}
+
+// DefaultFile return path of the global config file
+func DefaultFile() string {
+	return filepath.Join(cookie.ConfigDir(), FileName)
+}
+
+// DanmakuOptions convert danmaku config to options of converter
+func (c *Config) DanmakuOptions() danmaku.Options {
+	opts := danmaku.DefaultOptions()
+	opts.Font = c.Danmaku.Font
+	opts.FontSize = c.Danmaku.Size
+	opts.Alpha = c.Danmaku.Alpha
+	opts.Density = c.Danmaku.Density
+	opts.ScrollDuration = c.Danmaku.Duration
+	opts.NoTop = c.Danmaku.NoTop
+	opts.NoBottom = c.Danmaku.NoBottom
+	opts.AllowOverlap = c.Danmaku.Overlap
+	return opts
+}
+
+// RateLimitBytes return parsed rate limit, 0 for no limit
+func (c *Config) RateLimitBytes() (int64, error) {
+	if c.RateLimit == "" || c.RateLimit == "0" {
+		return 0, nil
+	}
+	b, err := consts.ParseByte(c.RateLimit)
+	return int64(b), err
+}
+
+// Validate check values can be used
+func (c *Config) Validate() error {
+	if _, err := outtmpl.Parse(c.Output); err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
+	if _, err := outtmpl.GetSanitizer(c.Sanitize); err != nil {
+		return fmt.Errorf("sanitize: %w", err)
+	}
+	if _, err := consts.ParseQuality(c.Quality); err != nil {
+		return fmt.Errorf("quality: %w", err)
+	}
+	switch c.Codec {
+	case "avc":
+	case "hevc", "av1":
+		// only durl streams are downloaded, bilibili serves hevc and av1 as dash only
+		return fmt.Errorf("codec: %v streams are dash only, only avc supported", c.Codec)
+	default:
+		return fmt.Errorf("codec: unknown codec %q", c.Codec)
+	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency: should not be negative")
+	}
+	if c.Jobs <= 0 {
+		return fmt.Errorf("jobs: should be positive")
+	}
+	if c.Connections < 0 {
+		return fmt.Errorf("connections: should not be negative")
+	}
+	if _, err := c.RateLimitBytes(); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+	if f := c.PostProcess.SubsFormat; f != "srt" && f != "vtt" {
+		return fmt.Errorf("postprocess.subs_format: unsupported subtitle format %v", f)
+	}
+	return nil
+}
+
+// field of config key
+type field struct {
+	key   string
+	index []int
+}
+
+var fields = func() []field {
+	var fs []field
+	var walk func(t reflect.Type, prefix string, index []int)
+	walk = func(t reflect.Type, prefix string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := prefix + f.Tag.Get("toml")
+			idx := append(append([]int{}, index...), i)
+			if f.Type.Kind() == reflect.Struct {
+				walk(f.Type, key+".", idx)
+				continue
+			}
+			fs = append(fs, field{key: key, index: idx})
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "", nil)
+	return fs
+}()
+
+func lookup(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Keys return all config keys in definition order
+func Keys() []string {
+	keys := make([]string, 0, len(fields))
+	for _, f := range fields {
+		keys = append(keys, f.key)
+	}
+	return keys
+}
+
+// EnvName return env var of key, BILIDOWN_PROXY_API for proxy.api
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Get return value of key as string
+func (c *Config) Get(key string) (string, error) {
+	f, ok := lookup(key)
+	if !ok {
+		return "", fmt.Errorf("unknown config key %v", key)
+	}
+	v := reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported type of %v", key)
+}
+
+// Set parse value and set to key
+func (c *Config) Set(key, value string) error {
+	f, ok := lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %v", key)
+	}
+	v := reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%v: invalid integer %q", key, value)
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%v: invalid bool %q", key, value)
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("%v: invalid number %q", key, value)
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type of %v", key)
+	}
+	return nil
+}
+
+// Sources record where value of key comes from, keys not in it are defaults
+type Sources map[string]string
+
+// Source return source of key
+func (s Sources) Source(key string) string {
+	if src, ok := s[key]; ok {
+		return src
+	}
+	return "default"
+}
+
+// LoadFile decode file into c, keys not in file are kept. missing file is not an error
+func (c *Config) LoadFile(file string, src Sources) error {
+	md, err := toml.DecodeFile(file, c)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("load config %v error: %w", file, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, k := range undecoded {
+			keys = append(keys, k.String())
+		}
+		return fmt.Errorf("unknown keys in config %v: %v", file, strings.Join(keys, ", "))
+	}
+	for _, k := range md.Keys() {
+		if _, ok := lookup(k.String()); ok && src != nil {
+			src[k.String()] = file
+		}
+	}
+	return nil
+}
+
+// LoadEnv set keys from env vars, getenv is os.LookupEnv usually
+func (c *Config) LoadEnv(getenv func(string) (string, bool), src Sources) error {
+	for _, key := range Keys() {
+		name := EnvName(key)
+		val, ok := getenv(name)
+		if !ok {
+			continue
+		}
+		if err := c.Set(key, val); err != nil {
+			return fmt.Errorf("env %v: %w", name, err)
+		}
+		if src != nil {
+			src[key] = "env " + name
+		}
+	}
+	return nil
+}
+
+// Load config from files then env vars, later files override former ones
+func Load(files []string, getenv func(string) (string, bool)) (*Config, Sources, error) {
+	c, src := Defaults(), Sources{}
+	for _, file := range files {
+		if err := c.LoadFile(file, src); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.LoadEnv(getenv, src); err != nil {
+		return nil, nil, err
+	}
+	return c, src, nil
+}
+
+// Write print config as toml, source of every key is written as comment
+func (c *Config) Write(w io.Writer, src Sources) error {
+	table := ""
+	var lines []string
+	for _, key := range Keys() {
+		name := key
+		if idx := strings.LastIndex(key, "."); idx != -1 {
+			if t := key[:idx]; t != table {
+				table = t
+				lines = append(lines, "", "["+t+"]")
+			}
+			name = key[idx+1:]
+		}
+		val, _ := c.Get(key)
+		f, _ := lookup(key)
+		if reflect.ValueOf(c).Elem().FieldByIndex(f.index).Kind() == reflect.String {
+			val = quote(val)
+		}
+		lines = append(lines, fmt.Sprintf("%v = %v # %v", name, val, src.Source(key)))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// quote string as toml basic string
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	require.Nil(t, os.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	global := writeFile(t, dir, "global.toml", `
+quality = "1080P"
+concurrency = 4
+rate_limit = "1M"
+
+[proxy]
+default = "socks5://127.0.0.1:1080"
+cdn = "direct"
+
+[danmaku]
+size = 36.5
+`)
+	prof := writeFile(t, dir, "profile.toml", `
+quality = "4K"
+
+[postprocess]
+write_nfo = true
+`)
+	conf, src, err := Load([]string{global, prof, filepath.Join(dir, "missing.toml")}, env(map[string]string{
+		"BILIDOWN_RATE_LIMIT":          "2M",
+		"BILIDOWN_POSTPROCESS_DANMAKU": "true",
+		"BILIDOWN_UNKNOWN":             "x",
+	}))
+	require.Nil(t, err)
+	require.Nil(t, conf.Validate())
+
+	require.Equal(t, "4K", conf.Quality)
+	require.Equal(t, prof, src.Source("quality"))
+	require.Equal(t, 4, conf.Concurrency)
+	require.Equal(t, global, src.Source("concurrency"))
+	require.Equal(t, "2M", conf.RateLimit)
+	require.Equal(t, "env BILIDOWN_RATE_LIMIT", src.Source("rate_limit"))
+	rate, err := conf.RateLimitBytes()
+	require.Nil(t, err)
+	require.EqualValues(t, 2<<20, rate)
+	require.Equal(t, Proxy{Default: "socks5://127.0.0.1:1080", CDN: "direct"}, conf.Proxy)
+	require.True(t, conf.PostProcess.WriteNFO)
+	require.True(t, conf.PostProcess.Danmaku)
+	require.Equal(t, 36.5, conf.DanmakuOptions().FontSize)
+
+	// untouched keys keep defaults
+	require.Equal(t, Defaults().Output, conf.Output)
+	require.Equal(t, "default", src.Source("output"))
+}
+
+func TestLoadError(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := Load([]string{writeFile(t, dir, "a.toml", "qualty = \"4K\"\n")}, env(nil))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "qualty")
+
+	_, _, err = Load([]string{writeFile(t, dir, "b.toml", "quality = \n")}, env(nil))
+	require.NotNil(t, err)
+
+	_, _, err = Load(nil, env(map[string]string{"BILIDOWN_CONCURRENCY": "many"}))
+	require.NotNil(t, err)
+
+	for key, val := range map[string]string{
+		"quality":                 "super",
+		"codec":                   "hevc",
+		"rate_limit":              "fast",
+		"sanitize":                "dos",
+		"postprocess.subs_format": "ass",
+		"concurrency":             "-1",
+		"jobs":                    "0",
+		"connections":             "-1",
+	} {
+		conf := Defaults()
+		require.Nil(t, conf.Set(key, val))
+		require.NotNil(t, conf.Validate(), key)
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	conf := Defaults()
+	require.Nil(t, conf.Validate())
+	for _, key := range Keys() {
+		val, err := conf.Get(key)
+		require.Nil(t, err, key)
+		require.Nil(t, conf.Set(key, val), key)
+	}
+	require.Equal(t, Defaults(), conf)
+
+	require.NotNil(t, conf.Set("nope", "1"))
+	require.NotNil(t, conf.Set("postprocess.write_nfo", "maybe"))
+	require.Equal(t, "BILIDOWN_PROXY_API", EnvName("proxy.api"))
+}
+
+func TestWrite(t *testing.T) {
+	conf := Defaults()
+	conf.Output = `{title} "quoted" \ path`
+	src := Sources{"output": "flag -o"}
+	buf := &bytes.Buffer{}
+	require.Nil(t, conf.Write(buf, src))
+	out := buf.String()
+	require.Contains(t, out, `output = "{title} \"quoted\" \\ path" # flag -o`)
+	require.Contains(t, out, "\n[proxy]\n")
+	require.Contains(t, out, "concurrency = 0 # default")
+
+	// written config can be loaded back
+	file := writeFile(t, t.TempDir(), "c.toml", out)
+	loaded, _, err := Load([]string{file}, env(nil))
+	require.Nil(t, err)
+	require.Equal(t, conf, loaded)
+	require.True(t, strings.HasSuffix(out, "\n"))
+}
@@ -1,6 +1,11 @@
 package consts
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const (
 	B  = 1
@@ -24,6 +29,9 @@ var FormatBiliToFile = map[string]string{
 	"mp4":     "mp4",
 }
 
+// CST is timezone of times returned by bilibili
+var CST = time.FixedZone("CST", 8*3600)
+
 type Byte int64
 
 func (b Byte) String() string {
@@ -47,3 +55,30 @@ func (b Byte) String() string {
 	}
 	return result
 }
+
+// ParseByte parse size like 512K, 1.5M, 2MB or 1048576, units are 1024 based
+func ParseByte(s string) (Byte, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "IB"), "B")
+	unit := int64(B)
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'K':
+			unit = KB
+		case 'M':
+			unit = MB
+		case 'G':
+			unit = GB
+		case 'T':
+			unit = TB
+		}
+		if unit != B {
+			str = str[:n-1]
+		}
+	}
+	val, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || val < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return Byte(val * float64(unit)), nil
+}
@@ -0,0 +1,27 @@
+package consts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByte(t *testing.T) {
+	for s, b := range map[string]Byte{
+		"1024": 1024,
+		"512K": 512 * KB,
+		"1.5M": MB + 512*KB,
+		"2MB":  2 * MB,
+		"2MiB": 2 * MB,
+		" 1g ": GB,
+		"100b": 100,
+	} {
+		got, err := ParseByte(s)
+		require.Nil(t, err, s)
+		require.Equal(t, b, got, s)
+	}
+	for _, s := range []string{"", "fast", "-1M", "M"} {
+		_, err := ParseByte(s)
+		require.NotNil(t, err, s)
+	}
+}
@@ -0,0 +1,88 @@
+package consts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// video quality, qn param of playurl
+const (
+	Qn240P     = 6
+	Qn360P     = 16
+	Qn480P     = 32
+	Qn720P     = 64
+	Qn720P60   = 74
+	Qn1080P    = 80
+	Qn1080PP   = 112 // 1080P high bitrate
+	Qn1080P60  = 116
+	Qn4K       = 120
+	QnHDR      = 125
+	QnDolby    = 126
+	Qn8K       = 127
+	QnBest     = Qn8K
+	QnNoLogin  = Qn480P  // best quality without login
+	QnLoggedIn = Qn1080P // best quality for accounts without vip
+)
+
+type Quality struct {
+	Qn   int64
+	Name string
+}
+
+// Qualities known qualities from best to worst
+var Qualities = []Quality{
+	{Qn8K, "8K"},
+	{QnDolby, "Dolby"},
+	{QnHDR, "HDR"},
+	{Qn4K, "4K"},
+	{Qn1080P60, "1080P60"},
+	{Qn1080PP, "1080P+"},
+	{Qn1080P, "1080P"},
+	{Qn720P60, "720P60"},
+	{Qn720P, "720P"},
+	{Qn480P, "480P"},
+	{Qn360P, "360P"},
+	{Qn240P, "240P"},
+}
+
+// QualityName return readable name of qn
+func QualityName(qn int64) string {
+	for _, q := range Qualities {
+		if q.Qn == qn {
+			return q.Name
+		}
+	}
+	return fmt.Sprintf("qn%d", qn)
+}
+
+// ParseQuality parse quality name like 1080P/4k or qn number
+func ParseQuality(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "best") {
+		return QnBest, nil
+	}
+	if qn, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return qn, nil
+	}
+	for _, q := range Qualities {
+		if strings.EqualFold(q.Name, s) {
+			return q.Qn, nil
+		}
+	}
+	names := make([]string, 0, len(Qualities))
+	for _, q := range Qualities {
+		names = append(names, q.Name)
+	}
+	return 0, fmt.Errorf("unknown quality %q, should be best, qn number or one of %v", s, strings.Join(names, ", "))
+}
+
+// NeedVIP report if quality is only available to vip accounts
+func NeedVIP(qn int64) bool {
+	return qn > QnLoggedIn
+}
+
+// NeedLogin report if quality is only available to logged in accounts
+func NeedLogin(qn int64) bool {
+	return qn > QnNoLogin
+}
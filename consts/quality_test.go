@@ -0,0 +1,31 @@
+package consts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuality(t *testing.T) {
+	for s, qn := range map[string]int64{
+		"":        QnBest,
+		"best":    QnBest,
+		"4k":      Qn4K,
+		"1080P+":  Qn1080PP,
+		"1080p60": Qn1080P60,
+		"64":      Qn720P,
+	} {
+		got, err := ParseQuality(s)
+		require.Nil(t, err, s)
+		require.Equal(t, qn, got, s)
+	}
+	_, err := ParseQuality("super")
+	require.NotNil(t, err)
+
+	require.Equal(t, "HDR", QualityName(QnHDR))
+	require.Equal(t, "qn999", QualityName(999))
+	require.True(t, NeedVIP(Qn1080PP))
+	require.False(t, NeedVIP(Qn1080P))
+	require.True(t, NeedLogin(Qn720P))
+	require.False(t, NeedLogin(Qn480P))
+}
@@ -0,0 +1,222 @@
+package danmaku
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Options control the generated ass subtitle
+type Options struct {
+	Width          int     // PlayResX of subtitle
+	Height         int     // PlayResY of subtitle
+	Font           string  // font name
+	FontSize       float64 // font size of normal(size 25) comment
+	Alpha          float64 // opacity of comments, 0 transparent to 1 opaque
+	ScrollDuration float64 // seconds a scrolling comment stays on screen
+	FixedDuration  float64 // seconds a top/bottom comment stays on screen
+	Density        float64 // share of screen height lanes can use, (0, 1]
+	NoScroll       bool    // drop scrolling comments
+	NoTop          bool    // drop top comments
+	NoBottom       bool    // drop bottom comments
+	AllowOverlap   bool    // place comment on the least busy lane instead of dropping it when all lanes are busy
+}
+
+// DefaultOptions return options for a 1080p video
+func DefaultOptions() Options {
+	return Options{
+		Width:          1920,
+		Height:         1080,
+		Font:           "Microsoft YaHei",
+		FontSize:       48,
+		Alpha:          0.8,
+		ScrollDuration: 8,
+		FixedDuration:  4,
+		Density:        1,
+	}
+}
+
+// ConvertXML read danmaku xml from r and write ass subtitle to w
+func ConvertXML(r io.Reader, w io.Writer, opts Options) error {
+	comments, err := ParseXML(r)
+	if err != nil {
+		return err
+	}
+	return WriteASS(w, comments, opts)
+}
+
+// WriteASS layout comments and write them as ass subtitle,
+// comments should be sorted by time, comments no lane fits are dropped
+func WriteASS(w io.Writer, comments []*Comment, opts Options) error {
+	if opts.Width <= 0 || opts.Height <= 0 || opts.FontSize <= 0 {
+		return fmt.Errorf("invalid size %vx%v, font size %v", opts.Width, opts.Height, opts.FontSize)
+	}
+	if opts.Density <= 0 || opts.Density > 1 {
+		opts.Density = 1
+	}
+
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, &opts)
+
+	laneCnt := int(float64(opts.Height) * opts.Density / opts.FontSize)
+	if laneCnt < 1 {
+		laneCnt = 1
+	}
+	var (
+		scroll  = newScrollLanes(laneCnt, &opts)
+		reverse = newScrollLanes(laneCnt, &opts)
+		top     = newFixedLanes(laneCnt, &opts)
+		bottom  = newFixedLanes(laneCnt, &opts)
+	)
+
+	for _, c := range comments {
+		var (
+			text          = strings.TrimSpace(c.Text)
+			width, height = textSize(text, fontSize(c, &opts))
+			lanes         = int(math.Ceil(height / opts.FontSize))
+		)
+		if text == "" {
+			continue
+		}
+		switch c.Mode {
+		case ModeScroll, ModeScroll2, ModeScroll3, ModeReverse:
+			if opts.NoScroll {
+				continue
+			}
+			ls := scroll
+			if c.Mode == ModeReverse {
+				ls = reverse
+			}
+			lane := ls.place(c.Time, width, lanes)
+			if lane < 0 {
+				continue
+			}
+			var (
+				y      = float64(lane) * opts.FontSize
+				x1, x2 = float64(opts.Width), -width
+			)
+			if c.Mode == ModeReverse {
+				x1, x2 = x2, x1
+			}
+			writeDialogue(bw, c, c.Time+opts.ScrollDuration, &opts,
+				fmt.Sprintf(`\move(%.0f,%.0f,%.0f,%.0f)`, x1, y, x2, y))
+		case ModeTop:
+			if opts.NoTop {
+				continue
+			}
+			lane := top.place(c.Time, lanes)
+			if lane < 0 {
+				continue
+			}
+			y := float64(lane) * opts.FontSize
+			writeDialogue(bw, c, c.Time+opts.FixedDuration, &opts,
+				fmt.Sprintf(`\an8\pos(%d,%.0f)`, opts.Width/2, y))
+		case ModeBottom:
+			if opts.NoBottom {
+				continue
+			}
+			lane := bottom.place(c.Time, lanes)
+			if lane < 0 {
+				continue
+			}
+			y := float64(opts.Height) - float64(lane)*opts.FontSize
+			writeDialogue(bw, c, c.Time+opts.FixedDuration, &opts,
+				fmt.Sprintf(`\an2\pos(%d,%.0f)`, opts.Width/2, y))
+		default:
+			// advanced and code comments are not supported
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeHeader(w *bufio.Writer, opts *Options) {
+	alpha := 255 - int(math.Round(opts.Alpha*255))
+	if alpha < 0 {
+		alpha = 0
+	} else if alpha > 255 {
+		alpha = 255
+	}
+	fmt.Fprintf(w, "[Script Info]\n")
+	fmt.Fprintf(w, "; Generated by bili-downloader\n")
+	fmt.Fprintf(w, "ScriptType: v4.00+\n")
+	fmt.Fprintf(w, "PlayResX: %d\n", opts.Width)
+	fmt.Fprintf(w, "PlayResY: %d\n", opts.Height)
+	fmt.Fprintf(w, "WrapStyle: 2\n")
+	fmt.Fprintf(w, "ScaledBorderAndShadow: yes\n\n")
+	fmt.Fprintf(w, "[V4+ Styles]\n")
+	fmt.Fprintf(w, "Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, "+
+		"Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, "+
+		"Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(w, "Style: Danmaku,%s,%.0f,&H%02XFFFFFF,&H%02XFFFFFF,&H%02X000000,&H%02X000000,"+
+		"0,0,0,0,100,100,0.00,0.00,1,1,0,7,0,0,0,0\n\n",
+		opts.Font, opts.FontSize, alpha, alpha, alpha, alpha)
+	fmt.Fprintf(w, "[Events]\n")
+	fmt.Fprintf(w, "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+}
+
+func writeDialogue(w *bufio.Writer, c *Comment, end float64, opts *Options, pos string) {
+	tags := pos
+	if c.Size != 0 && c.Size != 25 {
+		tags += fmt.Sprintf(`\fs%.0f`, fontSize(c, opts))
+	}
+	if color := c.Color & 0xffffff; color != 0xffffff {
+		// ass color is in BGR order
+		tags += fmt.Sprintf(`\c&H%02X%02X%02X&`, color&0xff, (color>>8)&0xff, color>>16)
+		if color == 0 {
+			tags += `\3c&HFFFFFF&`
+		}
+	}
+	fmt.Fprintf(w, "Dialogue: 2,%s,%s,Danmaku,,0000,0000,0000,,{%s}%s\n",
+		formatTime(c.Time), formatTime(end), tags, escapeText(c.Text))
+}
+
+// fontSize scale option font size by comment size
+func fontSize(c *Comment, opts *Options) float64 {
+	if c.Size <= 0 {
+		return opts.FontSize
+	}
+	return opts.FontSize * float64(c.Size) / 25
+}
+
+// textSize estimate rendered size of text, wide characters are treated as square
+func textSize(text string, size float64) (width, height float64) {
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		var w float64
+		for _, r := range line {
+			if r >= 0x1100 {
+				w += size
+			} else {
+				w += size / 2
+			}
+		}
+		if w > width {
+			width = w
+		}
+	}
+	return width, size * float64(len(lines))
+}
+
+// escapeText make text safe in dialogue of ass, ass has no escape of backslash,
+// so it's shown as fullwidth one to avoid being read as override tags
+func escapeText(text string) string {
+	return strings.NewReplacer(
+		`\`, `＼`,
+		"{", `\{`,
+		"}", `\}`,
+		"\r\n", `\N`,
+		"\n", `\N`,
+	).Replace(strings.TrimSpace(text))
+}
+
+// formatTime format seconds as h:mm:ss.cc
+func formatTime(sec float64) string {
+	cs := int64(math.Round(sec * 100))
+	if cs < 0 {
+		cs = 0
+	}
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
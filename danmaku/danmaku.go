@@ -0,0 +1,103 @@
+package danmaku
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mode of comment, defined by bilibili
+const (
+	ModeScroll   = 1
+	ModeScroll2  = 2
+	ModeScroll3  = 3
+	ModeBottom   = 4
+	ModeTop      = 5
+	ModeReverse  = 6
+	ModeAdvanced = 7
+	ModeCode     = 8
+)
+
+// Comment is a single danmaku
+type Comment struct {
+	Time      float64 // seconds from video start
+	Mode      int
+	Size      int // font size, 25 is normal
+	Color     int // rgb color
+	Timestamp int64
+	Pool      int
+	UserHash  string
+	ID        string
+	Text      string
+}
+
+type xmlDoc struct {
+	Items []struct {
+		P    string `xml:"p,attr"`
+		Text string `xml:",chardata"`
+	} `xml:"d"`
+}
+
+// ParseXML parse danmaku xml like https://comment.bilibili.com/{cid}.xml,
+// comments are returned sorted by appear time
+func ParseXML(r io.Reader) ([]*Comment, error) {
+	var doc xmlDoc
+	dec := xml.NewDecoder(r)
+	// danmaku xml may contain control characters, decoder is strict by default
+	dec.Strict = false
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	comments := make([]*Comment, 0, len(doc.Items))
+	for _, item := range doc.Items {
+		c, err := parseAttr(item.P)
+		if err != nil {
+			return nil, fmt.Errorf("parse comment %q error: %v", item.P, err)
+		}
+		c.Text = item.Text
+		comments = append(comments, c)
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Time < comments[j].Time
+	})
+
+	return comments, nil
+}
+
+// parseAttr parse p attribute: time,mode,size,color,timestamp,pool,hash,id[,weight]
+func parseAttr(p string) (*Comment, error) {
+	fields := strings.Split(p, ",")
+	if len(fields) < 8 {
+		return nil, fmt.Errorf("field count %v less than 8", len(fields))
+	}
+	var (
+		c   = &Comment{}
+		err error
+	)
+	if c.Time, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil, err
+	}
+	if c.Mode, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.Size, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, err
+	}
+	if c.Color, err = strconv.Atoi(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.Timestamp, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return nil, err
+	}
+	if c.Pool, err = strconv.Atoi(fields[5]); err != nil {
+		return nil, err
+	}
+	c.UserHash = fields[6]
+	c.ID = fields[7]
+
+	return c, nil
+}
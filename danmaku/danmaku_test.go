@@ -0,0 +1,94 @@
+package danmaku
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestParseXML(t *testing.T) {
+	f, err := os.Open("testdata/basic.xml")
+	require.Nil(t, err)
+	defer f.Close()
+
+	comments, err := ParseXML(f)
+	require.Nil(t, err)
+	require.Len(t, comments, 12)
+	// sorted by time
+	require.Equal(t, "first", comments[0].Text)
+	require.Equal(t, &Comment{
+		Time:      1.5,
+		Mode:      ModeScroll,
+		Size:      25,
+		Color:     0xffffff,
+		Timestamp: 1636300000,
+		Pool:      0,
+		UserHash:  "a1b2c3d4",
+		ID:        "100001",
+		Text:      "前方高能",
+	}, comments[1])
+
+	_, err = ParseXML(strings.NewReader(`<i><d p="1,2">bad</d></i>`))
+	require.NotNil(t, err)
+}
+
+func TestWriteASS(t *testing.T) {
+	crowded := DefaultOptions()
+	crowded.Height = 200
+	crowded.Density = 0.5
+
+	overlap := crowded
+	overlap.AllowOverlap = true
+
+	noFixed := DefaultOptions()
+	noFixed.NoTop = true
+	noFixed.NoBottom = true
+
+	cases := []struct {
+		name   string
+		input  string
+		golden string
+		opts   Options
+	}{
+		{"basic", "basic.xml", "basic.ass", DefaultOptions()},
+		{"crowded", "crowded.xml", "crowded.ass", crowded},
+		{"overlap", "crowded.xml", "crowded_overlap.ass", overlap},
+		{"no_fixed", "basic.xml", "basic_no_fixed.ass", noFixed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in, err := os.Open(filepath.Join("testdata", c.input))
+			require.Nil(t, err)
+			defer in.Close()
+
+			out := bytes.NewBuffer(nil)
+			require.Nil(t, ConvertXML(in, out, c.opts))
+
+			golden := filepath.Join("testdata", c.golden)
+			if *update {
+				require.Nil(t, os.WriteFile(golden, out.Bytes(), 0644))
+			}
+			expect, err := os.ReadFile(golden)
+			require.Nil(t, err)
+			require.Equal(t, string(expect), out.String())
+		})
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	require.Equal(t, "0:00:00.00", formatTime(0))
+	require.Equal(t, "0:01:01.50", formatTime(61.5))
+	require.Equal(t, "1:00:00.01", formatTime(3600.005))
+}
+
+func TestEscapeText(t *testing.T) {
+	require.Equal(t, `a＼Nb\{c\}\Nd`, escapeText(" a\\Nb{c}\r\nd \n"))
+}
@@ -0,0 +1,126 @@
+package danmaku
+
+type scrollItem struct {
+	start float64 // time the head enters screen
+	width float64
+}
+
+// scrollLanes allocate lanes for scrolling comments, a comment fits a lane
+// when it neither overlaps the tail of the previous one on entering nor
+// catches it up before the previous one leaves the screen
+type scrollLanes struct {
+	lanes []*scrollItem
+	opts  *Options
+}
+
+func newScrollLanes(n int, opts *Options) *scrollLanes {
+	return &scrollLanes{
+		lanes: make([]*scrollItem, n),
+		opts:  opts,
+	}
+}
+
+func (s *scrollLanes) speed(width float64) float64 {
+	return (float64(s.opts.Width) + width) / s.opts.ScrollDuration
+}
+
+func (s *scrollLanes) fits(lane int, start, width float64) bool {
+	prev := s.lanes[lane]
+	if prev == nil {
+		return true
+	}
+	// tail of previous comment must have entered screen
+	if start < prev.start+prev.width/s.speed(prev.width) {
+		return false
+	}
+	// head must not reach left edge before previous comment leaves
+	return start+float64(s.opts.Width)/s.speed(width) >= prev.start+s.opts.ScrollDuration
+}
+
+// place return the top lane of comment, -1 if dropped
+func (s *scrollLanes) place(start, width float64, height int) int {
+	last := len(s.lanes) - height
+	for lane := 0; lane <= last; lane++ {
+		ok := true
+		for i := lane; i < lane+height; i++ {
+			if !s.fits(i, start, width) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			s.occupy(lane, start, width, height)
+			return lane
+		}
+	}
+	if !s.opts.AllowOverlap || last < 0 {
+		return -1
+	}
+	// choose lane entered earliest
+	best := 0
+	for lane := 1; lane <= last; lane++ {
+		if s.lanes[lane].start < s.lanes[best].start {
+			best = lane
+		}
+	}
+	s.occupy(best, start, width, height)
+	return best
+}
+
+func (s *scrollLanes) occupy(lane int, start, width float64, height int) {
+	item := &scrollItem{
+		start: start,
+		width: width,
+	}
+	for i := lane; i < lane+height; i++ {
+		s.lanes[i] = item
+	}
+}
+
+// fixedLanes allocate lanes for top/bottom comments, a lane is free after
+// the previous comment disappeared
+type fixedLanes struct {
+	ends []float64
+	opts *Options
+}
+
+func newFixedLanes(n int, opts *Options) *fixedLanes {
+	return &fixedLanes{
+		ends: make([]float64, n),
+		opts: opts,
+	}
+}
+
+func (f *fixedLanes) place(start float64, height int) int {
+	last := len(f.ends) - height
+	for lane := 0; lane <= last; lane++ {
+		ok := true
+		for i := lane; i < lane+height; i++ {
+			if f.ends[i] > start {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			f.occupy(lane, start, height)
+			return lane
+		}
+	}
+	if !f.opts.AllowOverlap || last < 0 {
+		return -1
+	}
+	best := 0
+	for lane := 1; lane <= last; lane++ {
+		if f.ends[lane] < f.ends[best] {
+			best = lane
+		}
+	}
+	f.occupy(best, start, height)
+	return best
+}
+
+func (f *fixedLanes) occupy(lane int, start float64, height int) {
+	for i := lane; i < lane+height; i++ {
+		f.ends[i] = start + f.opts.FixedDuration
+	}
+}
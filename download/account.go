@@ -0,0 +1,179 @@
+package download
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	kNavUrl = "https://api.bilibili.com/x/web-interface/nav"
+)
+
+// vip types returned by nav api
+const (
+	VipNone    = 0
+	VipMonthly = 1
+	VipAnnual  = 2
+)
+
+type Account struct {
+	IsLogin    bool      `json:"is_login"`
+	Name       string    `json:"name,omitempty"`
+	Mid        int64     `json:"mid,omitempty"`
+	VipType    int64     `json:"vip_type"`
+	VipStatus  int64     `json:"vip_status"` // 1 for active
+	VipDueDate time.Time `json:"vip_due_date,omitempty"`
+	VipLabel   string    `json:"vip_label,omitempty"`
+}
+
+// IsVIP report if account has an active vip
+func (a *Account) IsVIP() bool {
+	return a.IsLogin && a.VipStatus == 1 && a.VipType != VipNone
+}
+
+// MaxQuality return best quality account can get
+func (a *Account) MaxQuality() int64 {
+	switch {
+	case a.IsVIP():
+		return consts.QnBest
+	case a.IsLogin:
+		return consts.QnLoggedIn
+	default:
+		return consts.QnNoLogin
+	}
+}
+
+var (
+	accountMu sync.Mutex
+	account   *Account
+)
+
+// GetAccount query login state of current cookies, cached after the first success
+func GetAccount() (*Account, error) {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	if account != nil {
+		return account, nil
+	}
+
+	data, err := getNav()
+	if err != nil {
+		return nil, err
+	}
+	account = parseAccount(data)
+	return account, nil
+}
+
+// getNav request nav api, wbi keys are updated by the way
+func getNav() (gjson.Result, error) {
+	req, err := http.NewRequest(http.MethodGet, kNavUrl, nil)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	start := time.Now()
+	resp, err := httpcli.Inst.Do(req)
+	if err != nil {
+		observeApi(req, start, nil)
+		return gjson.Result{}, err
+	}
+	defer resp.Body.Close()
+	buf, err := readApiResponse(resp)
+	observeApi(req, start, buf)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	// nav responds code -101 when not logged in, data is still filled
+	code := gjson.GetBytes(buf, "code").Int()
+	if code != 0 && code != -101 {
+		return gjson.Result{}, apiError(buf)
+	}
+	data := gjson.GetBytes(buf, "data")
+	wbiKeys.set(data)
+	return data, nil
+}
+
+// ResetAccount drop cached account, used after cookies changed
+func ResetAccount() {
+	accountMu.Lock()
+	account = nil
+	accountMu.Unlock()
+}
+
+func parseAccount(data gjson.Result) *Account {
+	a := &Account{
+		IsLogin:   data.Get("isLogin").Bool(),
+		Name:      data.Get("uname").String(),
+		Mid:       data.Get("mid").Int(),
+		VipType:   data.Get("vipType").Int(),
+		VipStatus: data.Get("vipStatus").Int(),
+		VipLabel:  data.Get("vip_label.text").String(),
+	}
+	if due := data.Get("vipDueDate").Int(); due > 0 {
+		a.VipDueDate = time.Unix(0, due*int64(time.Millisecond)).In(consts.CST)
+	}
+	return a
+}
+
+// checkQuality warn if account can't get quality qn, best means the best the account can get so it's never warned
+func checkQuality(qn int64) {
+	if qn >= consts.QnBest {
+		return
+	}
+	acc, err := GetAccount()
+	if err != nil {
+		log.Warnf("get account error, skip quality check: %v", err)
+		return
+	}
+	if qn <= acc.MaxQuality() {
+		return
+	}
+	switch {
+	case !acc.IsLogin:
+		log.Warnf("quality %v needs login, %v is the best without login, run `bilidown login` first",
+			consts.QualityName(qn), consts.QualityName(acc.MaxQuality()))
+	case consts.NeedVIP(qn):
+		log.Warnf("quality %v needs vip, %v is the best for account %v",
+			consts.QualityName(qn), consts.QualityName(acc.MaxQuality()), acc.Name)
+	}
+}
+
+// maxQuality return best quality of account, qualities aren't capped if account is unknown
+func maxQuality() int64 {
+	acc, err := GetAccount()
+	if err != nil {
+		return consts.QnBest
+	}
+	return acc.MaxQuality()
+}
+
+// checkDowngrade warn if playurl returned a worse quality than requested and the video has,
+// qualities above maxQn of account aren't expected
+func checkDowngrade(qn, maxQn int64, info *DownloadInfo) {
+	target := qn
+	if maxQn < target {
+		target = maxQn
+	}
+	if info.Qn >= target {
+		return
+	}
+	best := int64(0)
+	for _, q := range info.AcceptQuality {
+		if q <= target && q > best {
+			best = q
+		}
+	}
+	if info.Qn < best {
+		info.Downgraded = true
+		log.Warnf("playurl downgraded quality to %v, %v requested and %v available, check login or vip state",
+			consts.QualityName(info.Qn), consts.QualityName(qn), consts.QualityName(best))
+		return
+	}
+	log.Infof("quality %v not available, use %v", consts.QualityName(qn), consts.QualityName(info.Qn))
+}
@@ -0,0 +1,60 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseAccount(t *testing.T) {
+	acc := parseAccount(gjson.Parse(`{
+		"isLogin": true, "uname": "someone", "mid": 123,
+		"vipType": 2, "vipStatus": 1, "vipDueDate": 1767196800000,
+		"vip_label": {"text": "年度大会员"}
+	}`))
+	require.True(t, acc.IsVIP())
+	require.Equal(t, "someone", acc.Name)
+	require.EqualValues(t, 123, acc.Mid)
+	require.Equal(t, "2026-01-01", acc.VipDueDate.Format("2006-01-02"))
+	require.EqualValues(t, consts.QnBest, acc.MaxQuality())
+
+	// expired vip
+	acc = parseAccount(gjson.Parse(`{"isLogin": true, "uname": "someone", "vipType": 1, "vipStatus": 0}`))
+	require.False(t, acc.IsVIP())
+	require.EqualValues(t, consts.Qn1080P, acc.MaxQuality())
+
+	acc = parseAccount(gjson.Parse(`{"isLogin": false}`))
+	require.False(t, acc.IsLogin)
+	require.True(t, acc.VipDueDate.IsZero())
+	require.EqualValues(t, consts.Qn480P, acc.MaxQuality())
+}
+
+func TestCheckDowngrade(t *testing.T) {
+	info := &DownloadInfo{Qn: 80, AcceptQuality: []int64{120, 116, 80, 64, 32, 16}}
+
+	// 4K exists and vip can get it but 1080P returned
+	checkDowngrade(consts.QnBest, consts.QnBest, info)
+	require.True(t, info.Downgraded)
+
+	// best of account without vip returned
+	info.Downgraded = false
+	checkDowngrade(consts.QnBest, consts.QnLoggedIn, info)
+	require.False(t, info.Downgraded)
+
+	// 1080P requested and returned
+	checkDowngrade(consts.Qn1080P, consts.QnBest, info)
+	require.False(t, info.Downgraded)
+
+	// account without vip gets 720P though 1080P exists
+	info.Qn = 64
+	checkDowngrade(consts.QnBest, consts.QnLoggedIn, info)
+	require.True(t, info.Downgraded)
+
+	// best of video returned
+	info.Downgraded = false
+	info.Qn = 120
+	checkDowngrade(consts.QnBest, consts.QnBest, info)
+	require.False(t, info.Downgraded)
+}
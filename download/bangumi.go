@@ -0,0 +1,167 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	kSeasonUrl     = "https://api.bilibili.com/pgc/view/web/season"
+	kMediaUrl      = "https://api.bilibili.com/pgc/review/user"
+	kPGCPlayUrlUrl = "https://api.bilibili.com/pgc/player/web/playurl"
+)
+
+// isPGCId check if id is a bangumi id, ep/ss/md
+func isPGCId(id string) bool {
+	if len(id) < 2 {
+		return false
+	}
+	switch id[:2] {
+	case "ep", "ss", "md":
+		return true
+	}
+	return false
+}
+
+// getPGCResult request pgc api and return the result object
+func getPGCResult(u string, params map[string]string) (gjson.Result, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	q := req.URL.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	buf, err := doApiRequest(req)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	return gjson.GetBytes(buf, "result"), nil
+}
+
+// QuerySeason get every episode's aid, cid of a bangumi season
+func (p *UrlProcessor) QuerySeason() error {
+	num, _ := strconv.ParseInt(p.videoId[2:], 10, 64)
+	params := map[string]string{}
+	switch p.videoId[:2] {
+	case "ep":
+		params["ep_id"] = strconv.FormatInt(num, 10)
+	case "ss":
+		params["season_id"] = strconv.FormatInt(num, 10)
+	case "md":
+		// media id need convert to season id first
+		media, err := getPGCResult(kMediaUrl, map[string]string{
+			"media_id": strconv.FormatInt(num, 10),
+		})
+		if err != nil {
+			log.Errorf("query media %v error: %v", p.videoId, err)
+			return err
+		}
+		seasonId := media.Get("media.season_id").Int()
+		if seasonId == 0 {
+			return fmt.Errorf("season of media %v not found", p.videoId)
+		}
+		params["season_id"] = strconv.FormatInt(seasonId, 10)
+	default:
+		return fmt.Errorf("not a bangumi id: %v", p.videoId)
+	}
+
+	result, err := getPGCResult(kSeasonUrl, params)
+	if err != nil {
+		log.Errorf("query season %v error: %v", p.videoId, err)
+		return err
+	}
+
+	urls, err := parseSeason(result)
+	if err != nil {
+		return err
+	}
+	log.Infof("parse season for %v success, episodes count %v", p.videoId, len(urls))
+	p.urls = urls
+
+	return nil
+}
+
+// parseSeason parse season api result, episode no is used as page no
+func parseSeason(result gjson.Result) ([]*VideoInfo, error) {
+	episodes := result.Get("episodes")
+	if !episodes.Exists() {
+		return nil, errors.New("episodes not exists")
+	}
+	if !episodes.IsArray() {
+		return nil, errors.New("episodes not array")
+	}
+
+	title := result.Get("season_title").String()
+	if title == "" {
+		title = result.Get("title").String()
+	}
+
+	var (
+		infos []*VideoInfo
+		meta  = parseSeasonMeta(result)
+	)
+	for i, ep := range episodes.Array() {
+		epId := ep.Get("id").Int()
+		part := ep.Get("title").String()
+		if long := ep.Get("long_title").String(); long != "" {
+			part += " " + long
+		}
+		info := &VideoInfo{
+			VideoID:    "ep" + strconv.FormatInt(epId, 10),
+			Avid:       ep.Get("aid").Int(),
+			Cid:        ep.Get("cid").Int(),
+			EpID:       epId,
+			Title:      title,
+			Page:       int64(i + 1),
+			Duration:   ep.Get("duration").Int() / 1000, // milliseconds
+			PartName:   part,
+			FirstFrame: httpsUrl(ep.Get("cover").String()),
+			Meta:       meta,
+		}
+		log.Infof("parse episode %v, part %v success", info.Page, part)
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// GetDownloadInfoByEpCid get download info of bangumi episode with quality qn
+func GetDownloadInfoByEpCid(videoId string, epid, avid, cid, qn int64) (*DownloadInfo, error) {
+	params := map[string]string{
+		"ep_id": strconv.FormatInt(epid, 10),
+		"cid":   strconv.FormatInt(cid, 10),
+		"otype": "json",
+		"qn":    strconv.FormatInt(qn, 10),
+		"fourk": "1",
+		"fnver": "0",
+		"fnval": "0",
+	}
+
+	result, err := getPGCResult(kPGCPlayUrlUrl, params)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := parsePlayUrl(result)
+	if err != nil {
+		return nil, err
+	}
+	info.VideoID = videoId
+	info.Avid = avid
+	info.Cid = cid
+	checkDowngrade(qn, maxQuality(), info)
+
+	return info, nil
+}
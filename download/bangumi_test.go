@@ -0,0 +1,60 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestCheckArgs(t *testing.T) {
+	for _, id := range []string{"av170001", "BV1pP4y1b7iP", "ep123", "ss456", "md789"} {
+		p := &UrlProcessor{videoId: id}
+		require.Nil(t, p.CheckArgs(), id)
+	}
+	for _, id := range []string{"a", "xx123", "epabc", "ss", "md12x"} {
+		p := &UrlProcessor{videoId: id}
+		require.NotNil(t, p.CheckArgs(), id)
+	}
+}
+
+func TestParseSeason(t *testing.T) {
+	const result = `{
+		"season_id": 456,
+		"season_title": "Season Title",
+		"title": "Title",
+		"episodes": [
+			{"id": 1001, "aid": 11, "cid": 21, "title": "1", "long_title": "First", "duration": 1440000},
+			{"id": 1002, "aid": 12, "cid": 22, "title": "PV", "long_title": "", "duration": 90500}
+		]
+	}`
+	infos, err := parseSeason(gjson.Parse(result))
+	require.Nil(t, err)
+	require.Len(t, infos, 2)
+
+	// meta is shared by episodes
+	meta := infos[0].Meta
+	require.NotNil(t, meta)
+	require.Same(t, meta, infos[1].Meta)
+	require.EqualValues(t, 456, meta.SeasonID)
+	require.Equal(t, "Season Title", meta.Title)
+
+	infos[0].Meta = nil
+	require.Equal(t, &VideoInfo{
+		VideoID:  "ep1001",
+		Avid:     11,
+		Cid:      21,
+		EpID:     1001,
+		Title:    "Season Title",
+		Page:     1,
+		Duration: 1440,
+		PartName: "1 First",
+	}, infos[0])
+	require.EqualValues(t, 2, infos[1].Page)
+	require.Equal(t, "PV", infos[1].PartName)
+	require.True(t, infos[1].IsPGC())
+	require.Equal(t, "https://www.bilibili.com/bangumi/play/ep1002", videoPageUrl(infos[1].VideoID))
+
+	_, err = parseSeason(gjson.Parse(`{"title": "x"}`))
+	require.NotNil(t, err)
+}
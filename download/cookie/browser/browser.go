@@ -0,0 +1,353 @@
+package browser
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download/cookie"
+)
+
+// Domain is the cookie domain read from browsers
+const Domain = "bilibili.com"
+
+// chromiumDirs is config directories of chromium based browsers on linux
+var chromiumDirs = map[string][]string{
+	"chromium": {"chromium", "../snap/chromium/common/chromium"},
+	"chrome":   {"google-chrome"},
+	"brave":    {"BraveSoftware/Brave-Browser"},
+	"edge":     {"microsoft-edge"},
+}
+
+// Browsers return supported browser names
+func Browsers() []string {
+	names := []string{"firefox"}
+	for k := range chromiumDirs {
+		names = append(names, k)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Load read bilibili cookies from browser, spec is browser[:profile], profile is
+// a profile directory name, path of profile directory or cookie database,
+// default profile is used if omitted
+func Load(spec string) ([]*cookie.Cookie, error) {
+	name, profile := spec, ""
+	if idx := strings.IndexByte(spec, ':'); idx != -1 {
+		name, profile = spec[:idx], spec[idx+1:]
+	}
+	name = strings.ToLower(name)
+
+	if name == "firefox" {
+		db, err := findFirefoxCookies(profile)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("read firefox cookies from %v", db)
+		return ReadFirefox(db)
+	}
+	if dirs, ok := chromiumDirs[name]; ok {
+		db, err := findChromiumCookies(dirs, profile)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("read %v cookies from %v", name, db)
+		return ReadChromium(db)
+	}
+	return nil, fmt.Errorf("unsupported browser %q, should be one of %v", name, Browsers())
+}
+
+func isFile(name string) bool {
+	st, err := os.Stat(name)
+	return err == nil && !st.IsDir()
+}
+
+func findFirefoxCookies(profile string) (string, error) {
+	if isFile(profile) {
+		return profile, nil
+	}
+	if profile != "" && isFile(filepath.Join(profile, "cookies.sqlite")) {
+		return filepath.Join(profile, "cookies.sqlite"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	var candidates []string
+	for _, root := range []string{
+		filepath.Join(home, ".mozilla", "firefox"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox"),
+		filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+	} {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			db := filepath.Join(root, e.Name(), "cookies.sqlite")
+			if !e.IsDir() || !isFile(db) {
+				continue
+			}
+			if profile != "" {
+				// match by directory name like xxxxxxxx.default-release or its suffix
+				if e.Name() == profile || strings.HasSuffix(e.Name(), "."+profile) {
+					return db, nil
+				}
+				continue
+			}
+			candidates = append(candidates, db)
+		}
+	}
+	if len(candidates) == 0 {
+		if profile != "" {
+			return "", fmt.Errorf("firefox profile %v not found", profile)
+		}
+		return "", errors.New("no firefox profile found")
+	}
+	// prefer profile used by release channel
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return profileRank(candidates[i]) < profileRank(candidates[j])
+	})
+	return candidates[0], nil
+}
+
+func profileRank(db string) int {
+	dir := filepath.Base(filepath.Dir(db))
+	switch {
+	case strings.HasSuffix(dir, ".default-release"):
+		return 0
+	case strings.Contains(dir, ".default"):
+		return 1
+	}
+	return 2
+}
+
+func findChromiumCookies(dirs []string, profile string) (string, error) {
+	if isFile(profile) {
+		return profile, nil
+	}
+	if profile != "" {
+		for _, db := range []string{filepath.Join(profile, "Network", "Cookies"), filepath.Join(profile, "Cookies")} {
+			if isFile(db) {
+				return db, nil
+			}
+		}
+	} else {
+		profile = "Default"
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range dirs {
+		base := filepath.Join(configDir, dir, profile)
+		// newer versions keep cookies under Network
+		for _, db := range []string{filepath.Join(base, "Network", "Cookies"), filepath.Join(base, "Cookies")} {
+			if isFile(db) {
+				return db, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cookies of profile %v not found", profile)
+}
+
+func matchDomain(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), ".")
+	return host == Domain || strings.HasSuffix(host, "."+Domain)
+}
+
+func toInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return ""
+}
+
+// ReadFirefox read bilibili cookies from firefox cookies.sqlite
+func ReadFirefox(db string) ([]*cookie.Cookie, error) {
+	sq, err := openSqlite(db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sq.rows("moz_cookies")
+	if err != nil {
+		return nil, err
+	}
+
+	var cks []*cookie.Cookie
+	for _, r := range rows {
+		host := toString(r["host"])
+		if !matchDomain(host) {
+			continue
+		}
+		c := &cookie.Cookie{
+			Name:     toString(r["name"]),
+			Value:    toString(r["value"]),
+			Domain:   strings.TrimPrefix(host, "."),
+			HostOnly: !strings.HasPrefix(host, "."),
+			Path:     toString(r["path"]),
+			Secure:   toInt(r["isSecure"]) != 0,
+			HttpOnly: toInt(r["isHttpOnly"]) != 0,
+		}
+		if exp := toInt(r["expiry"]); exp > 0 {
+			// newer versions save expiry in milliseconds
+			if exp > 1e11 {
+				c.Expires = time.Unix(0, exp*int64(time.Millisecond))
+			} else {
+				c.Expires = time.Unix(exp, 0)
+			}
+		}
+		cks = append(cks, c)
+	}
+	return cks, nil
+}
+
+// chromiumEpoch is base of chromium timestamps, 1601-01-01 in microseconds before unix epoch
+const chromiumEpoch = 11644473600 * 1000 * 1000
+
+// ReadChromium read bilibili cookies from chromium Cookies database, encrypted
+// values are decrypted with key used when no keyring is available
+func ReadChromium(db string) ([]*cookie.Cookie, error) {
+	sq, err := openSqlite(db)
+	if err != nil {
+		return nil, err
+	}
+	// host key hash is prepended to value since database version 24
+	var version int64
+	if metas, err := sq.rows("meta"); err == nil {
+		for _, m := range metas {
+			if toString(m["key"]) == "version" {
+				version = toInt(m["value"])
+			}
+		}
+	}
+	rows, err := sq.rows("cookies")
+	if err != nil {
+		return nil, err
+	}
+
+	var cks []*cookie.Cookie
+	for _, r := range rows {
+		host := toString(r["host_key"])
+		if !matchDomain(host) {
+			continue
+		}
+		value := toString(r["value"])
+		if enc, _ := r["encrypted_value"].([]byte); value == "" && len(enc) != 0 {
+			plain, err := decryptChromium(enc, host, version)
+			if err != nil {
+				log.Warnf("decrypt cookie %v error: %v", toString(r["name"]), err)
+				continue
+			}
+			value = plain
+		}
+		secure, httpOnly := r["is_secure"], r["is_httponly"]
+		if secure == nil {
+			// column names before chromium 80
+			secure, httpOnly = r["secure"], r["httponly"]
+		}
+		c := &cookie.Cookie{
+			Name:     toString(r["name"]),
+			Value:    value,
+			Domain:   strings.TrimPrefix(host, "."),
+			HostOnly: !strings.HasPrefix(host, "."),
+			Path:     toString(r["path"]),
+			Secure:   toInt(secure) != 0,
+			HttpOnly: toInt(httpOnly) != 0,
+		}
+		hasExpires := r["has_expires"] == nil || toInt(r["has_expires"]) != 0
+		if exp := toInt(r["expires_utc"]); exp > 0 && hasExpires {
+			c.Expires = time.Unix(0, (exp-chromiumEpoch)*int64(time.Microsecond))
+		}
+		cks = append(cks, c)
+	}
+	return cks, nil
+}
+
+// chromiumPasswords is passwords tried for linux, peanuts is used by v10 and by v11
+// when basic password store is used, empty password is used when keyring is unavailable
+var chromiumPasswords = []string{"peanuts", ""}
+
+func chromiumKey(password string) []byte {
+	// pbkdf2-hmac-sha1 with 1 iteration is the first hmac block
+	mac := hmac.New(sha1.New, []byte(password))
+	mac.Write([]byte("saltysalt"))
+	mac.Write([]byte{0, 0, 0, 1})
+	return mac.Sum(nil)[:16]
+}
+
+func decryptChromium(enc []byte, host string, version int64) (string, error) {
+	if len(enc) < 3 || (string(enc[:3]) != "v10" && string(enc[:3]) != "v11") {
+		return "", errors.New("unknown encryption version")
+	}
+	data := enc[3:]
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return "", errors.New("invalid encrypted value length")
+	}
+	for _, password := range chromiumPasswords {
+		block, _ := aes.NewCipher(chromiumKey(password))
+		plain := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, []byte(strings.Repeat(" ", aes.BlockSize))).CryptBlocks(plain, data)
+		plain, ok := unpad(plain)
+		if !ok {
+			continue
+		}
+		if version >= 24 && len(plain) >= sha256.Size {
+			sum := sha256.Sum256([]byte(host))
+			if string(plain[:sha256.Size]) == string(sum[:]) {
+				plain = plain[sha256.Size:]
+			}
+		}
+		if !utf8.Valid(plain) {
+			continue
+		}
+		return string(plain), nil
+	}
+	return "", errors.New("wrong key, keyring encrypted cookies are not supported")
+}
+
+// unpad remove pkcs7 padding
+func unpad(b []byte) ([]byte, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, false
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return nil, false
+		}
+	}
+	return b[:len(b)-n], true
+}
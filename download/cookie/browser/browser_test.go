@@ -0,0 +1,114 @@
+package browser
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFirefox(t *testing.T) {
+	cks, err := ReadFirefox("testdata/firefox.sqlite")
+	require.Nil(t, err)
+	require.Len(t, cks, 4)
+
+	byName := map[string]*cookie.Cookie{}
+	for _, c := range cks {
+		byName[c.Name] = c
+	}
+	require.Equal(t, &cookie.Cookie{
+		Name:     "SESSDATA",
+		Value:    "sess%2C1",
+		Domain:   "bilibili.com",
+		Path:     "/",
+		Expires:  time.Unix(4102444800, 0),
+		Secure:   true,
+		HttpOnly: true,
+	}, byName["SESSDATA"])
+	require.True(t, byName["buvid3"].HostOnly)
+	require.Equal(t, "www.bilibili.com", byName["buvid3"].Domain)
+	require.True(t, byName["buvid3"].Expires.Equal(time.Unix(4102444800, 0)))
+	// value spans overflow pages
+	require.Len(t, byName["long"].Value, 3000)
+	// row only committed in wal
+	require.Equal(t, "jct", byName["bili_jct"].Value)
+}
+
+func TestReadChromium(t *testing.T) {
+	for _, db := range []string{"testdata/chromium_v24.sqlite", "testdata/chromium_v21.sqlite"} {
+		cks, err := ReadChromium(db)
+		require.Nil(t, err, db)
+		require.Len(t, cks, 2, db)
+
+		require.Equal(t, &cookie.Cookie{
+			Name:     "SESSDATA",
+			Value:    "sess,1",
+			Domain:   "bilibili.com",
+			Path:     "/",
+			Expires:  time.Unix(4102444800, 0),
+			Secure:   true,
+			HttpOnly: true,
+		}, cks[0], db)
+		require.Equal(t, &cookie.Cookie{
+			Name:     "buvid3",
+			Value:    "plain",
+			Domain:   "www.bilibili.com",
+			HostOnly: true,
+			Path:     "/",
+		}, cks[1], db)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cks, err := Load("firefox:testdata/firefox.sqlite")
+	require.Nil(t, err)
+	require.Len(t, cks, 4)
+
+	cks, err = Load("chromium:testdata/chromium_v24.sqlite")
+	require.Nil(t, err)
+	require.Len(t, cks, 2)
+
+	_, err = Load("netscape")
+	require.NotNil(t, err)
+}
+
+func TestOpenSqlite(t *testing.T) {
+	_, err := openSqlite("browser.go")
+	require.Equal(t, errNotSqlite, err)
+
+	cols, rowidAt := parseColumns(`CREATE TABLE t ("id" INTEGER PRIMARY KEY, name TEXT DEFAULT (lower('A')), v BLOB, UNIQUE (name, v))`)
+	require.Equal(t, []string{"id", "name", "v"}, cols)
+	require.Equal(t, 0, rowidAt)
+}
+
+func TestSqliteCorrupt(t *testing.T) {
+	for _, name := range []string{"testdata/firefox.sqlite", "testdata/chromium_v24.sqlite"} {
+		data, err := os.ReadFile(name)
+		require.Nil(t, err)
+		wal, _ := os.ReadFile(name + "-wal")
+
+		read := func(data []byte) {
+			db, err := newSqlite(data)
+			if err != nil {
+				return
+			}
+			db.loadWal(wal)
+			db.rows("moz_cookies")
+			db.rows("cookies")
+		}
+		// file copied while browser is writing it is truncated or has garbage in pages,
+		// errors are expected but never panics
+		for n := 0; n < len(data); n += 61 {
+			require.NotPanics(t, func() { read(data[:n]) }, "%v truncated at %v", name, n)
+		}
+		for off := 100; off < len(data); off += 41 {
+			for _, b := range []byte{0x00, 0xff, 0x7f} {
+				broken := append([]byte(nil), data...)
+				broken[off] = b
+				require.NotPanics(t, func() { read(broken) }, "%v byte %v set to %#x", name, off, b)
+			}
+		}
+	}
+}
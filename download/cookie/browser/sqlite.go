@@ -0,0 +1,436 @@
+package browser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// sqliteDB is a minimal read-only sqlite3 reader, only full scan of rowid
+// tables is supported, which is enough for reading browser cookie stores
+// without cgo. Committed frames in the -wal file are applied when present.
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int
+	wal      map[uint32][]byte // page no -> latest committed page in wal
+}
+
+var errNotSqlite = errors.New("not a sqlite3 database")
+
+func openSqlite(name string) (*sqliteDB, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	db, err := newSqlite(data)
+	if err != nil {
+		return nil, err
+	}
+	if wal, err := os.ReadFile(name + "-wal"); err == nil {
+		db.loadWal(wal)
+	}
+	return db, nil
+}
+
+// newSqlite check header of database file
+func newSqlite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || string(data[:16]) != "SQLite format 3\x00" {
+		return nil, errNotSqlite
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %v", pageSize)
+	}
+	if enc := binary.BigEndian.Uint32(data[56:60]); enc > 1 {
+		return nil, fmt.Errorf("unsupported text encoding %v", enc)
+	}
+	db := &sqliteDB{
+		data:     data,
+		pageSize: pageSize,
+		usable:   pageSize - int(data[20]),
+	}
+	if db.usable < 480 {
+		return nil, fmt.Errorf("invalid reserved space %v", data[20])
+	}
+	return db, nil
+}
+
+// loadWal index frames of wal file up to the last commit frame
+func (db *sqliteDB) loadWal(wal []byte) {
+	const (
+		hdrSize   = 32
+		frameHdr  = 24
+		walMagic1 = 0x377f0682
+		walMagic2 = 0x377f0683
+	)
+	if len(wal) < hdrSize {
+		return
+	}
+	magic := binary.BigEndian.Uint32(wal[0:4])
+	if magic != walMagic1 && magic != walMagic2 {
+		return
+	}
+	if int(binary.BigEndian.Uint32(wal[8:12])) != db.pageSize {
+		return
+	}
+	salt1, salt2 := binary.BigEndian.Uint32(wal[16:20]), binary.BigEndian.Uint32(wal[20:24])
+
+	var (
+		pending   = map[uint32][]byte{}
+		committed = map[uint32][]byte{}
+	)
+	for off := hdrSize; off+frameHdr+db.pageSize <= len(wal); off += frameHdr + db.pageSize {
+		hdr := wal[off : off+frameHdr]
+		if binary.BigEndian.Uint32(hdr[8:12]) != salt1 || binary.BigEndian.Uint32(hdr[12:16]) != salt2 {
+			// frames left by previous checkpoints
+			break
+		}
+		pgno := binary.BigEndian.Uint32(hdr[0:4])
+		pending[pgno] = wal[off+frameHdr : off+frameHdr+db.pageSize]
+		if binary.BigEndian.Uint32(hdr[4:8]) != 0 {
+			for k, v := range pending {
+				committed[k] = v
+			}
+			pending = map[uint32][]byte{}
+		}
+	}
+	if len(committed) != 0 {
+		db.wal = committed
+	}
+}
+
+func (db *sqliteDB) page(no uint32) ([]byte, error) {
+	if p, ok := db.wal[no]; ok {
+		return p, nil
+	}
+	start := int(no-1) * db.pageSize
+	if no == 0 || start+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("page %v out of range", no)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// row is a table row, values are nil, int64, float64, string or []byte
+type row struct {
+	rowid  int64
+	values []interface{}
+}
+
+// scan walk table b-tree rooted at page root
+func (db *sqliteDB) scan(root uint32, fn func(r *row) error) error {
+	return db.walk(root, fn, map[uint32]bool{})
+}
+
+// walk visit pages of b-tree in order, a page linked twice means a broken file
+func (db *sqliteDB) walk(no uint32, fn func(r *row) error, visited map[uint32]bool) error {
+	if visited[no] {
+		return fmt.Errorf("page %v linked more than once", no)
+	}
+	visited[no] = true
+	p, err := db.page(no)
+	if err != nil {
+		return err
+	}
+	hdr := 0
+	if no == 1 {
+		hdr = 100
+	}
+	var (
+		typ      = p[hdr]
+		cellCnt  = int(binary.BigEndian.Uint16(p[hdr+3 : hdr+5]))
+		cellsOff = hdr + 8
+	)
+	if typ == 0x05 {
+		cellsOff = hdr + 12
+	}
+	// offsets read from file are checked against page as the file may be truncated or copied while written
+	if cellsOff+2*cellCnt > len(p) {
+		return fmt.Errorf("page %v: cell pointers out of page", no)
+	}
+	cell := func(i int) (int, error) {
+		ptr := int(binary.BigEndian.Uint16(p[cellsOff+2*i:]))
+		if ptr < cellsOff+2*cellCnt || ptr >= len(p) || (typ == 0x05 && ptr+4 > len(p)) {
+			return 0, fmt.Errorf("page %v cell %v: offset %v out of page", no, i, ptr)
+		}
+		return ptr, nil
+	}
+	switch typ {
+	case 0x05: // interior table page
+		for i := 0; i < cellCnt; i++ {
+			ptr, err := cell(i)
+			if err != nil {
+				return err
+			}
+			if err := db.walk(binary.BigEndian.Uint32(p[ptr:]), fn, visited); err != nil {
+				return err
+			}
+		}
+		return db.walk(binary.BigEndian.Uint32(p[hdr+8:]), fn, visited)
+	case 0x0d: // leaf table page
+		for i := 0; i < cellCnt; i++ {
+			ptr, err := cell(i)
+			if err != nil {
+				return err
+			}
+			r, err := db.leafCell(p, ptr)
+			if err != nil {
+				return fmt.Errorf("page %v cell %v: %v", no, i, err)
+			}
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("page %v is not a table page: type %#x", no, typ)
+	}
+}
+
+func (db *sqliteDB) leafCell(p []byte, off int) (*row, error) {
+	size, n := readVarint(p[off:])
+	off += n
+	rowid, n := readVarint(p[off:])
+	off += n
+
+	// payload can't be larger than all pages of database
+	if size > uint64(len(db.data)+len(db.wal)*db.pageSize) {
+		return nil, fmt.Errorf("payload size %v out of range", size)
+	}
+	var (
+		payloadSize = int(size)
+		u           = db.usable
+		x           = u - 35
+		local       = payloadSize
+	)
+	if payloadSize > x {
+		m := (u-12)*32/255 - 23
+		k := m + (payloadSize-m)%(u-4)
+		if k <= x {
+			local = k
+		} else {
+			local = m
+		}
+	}
+	if off+local > len(p) || (local < payloadSize && off+local+4 > len(p)) {
+		return nil, errors.New("cell out of page")
+	}
+	payload := make([]byte, 0, payloadSize)
+	payload = append(payload, p[off:off+local]...)
+	if local < payloadSize {
+		next := binary.BigEndian.Uint32(p[off+local:])
+		for len(payload) < payloadSize {
+			if next == 0 {
+				return nil, errors.New("overflow chain too short")
+			}
+			op, err := db.page(next)
+			if err != nil {
+				return nil, err
+			}
+			next = binary.BigEndian.Uint32(op[0:4])
+			take := payloadSize - len(payload)
+			if take > u-4 {
+				take = u - 4
+			}
+			payload = append(payload, op[4:4+take]...)
+		}
+	}
+
+	values, err := decodeRecord(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &row{rowid: int64(rowid), values: values}, nil
+}
+
+func decodeRecord(rec []byte) ([]interface{}, error) {
+	hdrSize, n := readVarint(rec)
+	if hdrSize > uint64(len(rec)) {
+		return nil, errors.New("record header out of range")
+	}
+	var types []uint64
+	for off := n; off < int(hdrSize); {
+		t, m := readVarint(rec[off:])
+		types = append(types, t)
+		off += m
+	}
+
+	values := make([]interface{}, 0, len(types))
+	body := rec[hdrSize:]
+	for _, t := range types {
+		var (
+			v    interface{}
+			size int
+		)
+		switch {
+		case t == 0:
+			v = nil
+		case t >= 1 && t <= 6:
+			size = []int{0, 1, 2, 3, 4, 6, 8}[t]
+			if len(body) < size {
+				return nil, errors.New("record body out of range")
+			}
+			var iv int64
+			for i := 0; i < size; i++ {
+				iv = iv<<8 | int64(body[i])
+			}
+			// sign extend
+			shift := uint(64 - 8*size)
+			v = iv << shift >> shift
+		case t == 7:
+			size = 8
+			if len(body) < size {
+				return nil, errors.New("record body out of range")
+			}
+			v = math.Float64frombits(binary.BigEndian.Uint64(body))
+		case t == 8:
+			v = int64(0)
+		case t == 9:
+			v = int64(1)
+		case t >= 12 && (t-12)/2 > uint64(len(body)):
+			return nil, errors.New("record body out of range")
+		case t >= 12 && t%2 == 0:
+			size = int(t-12) / 2
+			if len(body) < size {
+				return nil, errors.New("record body out of range")
+			}
+			v = append([]byte(nil), body[:size]...)
+		case t >= 13:
+			size = int(t-13) / 2
+			if len(body) < size {
+				return nil, errors.New("record body out of range")
+			}
+			v = string(body[:size])
+		default:
+			return nil, fmt.Errorf("invalid serial type %v", t)
+		}
+		body = body[size:]
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// readVarint read sqlite big-endian varint, at most 9 bytes
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// table is schema of a table
+type table struct {
+	root    uint32
+	columns []string
+	rowidAt int // index of INTEGER PRIMARY KEY column, -1 if none
+}
+
+// table find table schema in sqlite_master
+func (db *sqliteDB) table(name string) (*table, error) {
+	var found *table
+	err := db.scan(1, func(r *row) error {
+		// type, name, tbl_name, rootpage, sql
+		if len(r.values) < 5 || r.values[0] != "table" || !strings.EqualFold(fmt.Sprint(r.values[1]), name) {
+			return nil
+		}
+		root, _ := r.values[3].(int64)
+		sql, _ := r.values[4].(string)
+		cols, rowidAt := parseColumns(sql)
+		found = &table{root: uint32(root), columns: cols, rowidAt: rowidAt}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("table %v not found", name)
+	}
+	return found, nil
+}
+
+// parseColumns get column names from CREATE TABLE statement
+func parseColumns(sql string) ([]string, int) {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start == -1 || end <= start {
+		return nil, -1
+	}
+	var (
+		defs  []string
+		depth int
+		last  = start + 1
+	)
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[last:end])
+
+	var (
+		cols    []string
+		rowidAt = -1
+	)
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "CONSTRAINT":
+			continue
+		}
+		upper := strings.ToUpper(strings.Join(fields[1:], " "))
+		if strings.HasPrefix(upper, "INTEGER PRIMARY KEY") {
+			rowidAt = len(cols)
+		}
+		cols = append(cols, strings.Trim(fields[0], "\"`[]'"))
+	}
+	return cols, rowidAt
+}
+
+// rows read every row of table as column name to value map
+func (db *sqliteDB) rows(name string) ([]map[string]interface{}, error) {
+	t, err := db.table(name)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	err = db.scan(t.root, func(r *row) error {
+		m := make(map[string]interface{}, len(t.columns))
+		for i, col := range t.columns {
+			if i < len(r.values) {
+				m[col] = r.values[i]
+			} else {
+				// column added by ALTER TABLE after row written
+				m[col] = nil
+			}
+		}
+		if t.rowidAt != -1 {
+			m[t.columns[t.rowidAt]] = r.rowid
+		}
+		rows = append(rows, m)
+		return nil
+	})
+	return rows, err
+}
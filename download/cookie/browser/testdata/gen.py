@@ -0,0 +1,99 @@
+#!/usr/bin/env python3
+# Generate fixture cookie databases, run in this directory. Needs openssl for
+# encrypting chromium values.
+import hashlib
+import os
+import shutil
+import sqlite3
+import subprocess
+
+FF_SCHEMA = """CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT NOT NULL DEFAULT '',
+name TEXT, value TEXT, host TEXT, path TEXT, expiry INTEGER, lastAccessed INTEGER, creationTime INTEGER,
+isSecure INTEGER, isHttpOnly INTEGER, inBrowserElement INTEGER DEFAULT 0, sameSite INTEGER DEFAULT 0,
+rawSameSite INTEGER DEFAULT 0, schemeMap INTEGER DEFAULT 0,
+CONSTRAINT moz_uniqueid UNIQUE (name, host, path, originAttributes))"""
+
+CR_SCHEMA = """CREATE TABLE cookies(creation_utc INTEGER NOT NULL,host_key TEXT NOT NULL,
+top_frame_site_key TEXT NOT NULL,name TEXT NOT NULL,value TEXT NOT NULL,encrypted_value BLOB NOT NULL,
+path TEXT NOT NULL,expires_utc INTEGER NOT NULL,is_secure INTEGER NOT NULL,is_httponly INTEGER NOT NULL,
+last_access_utc INTEGER NOT NULL,has_expires INTEGER NOT NULL,is_persistent INTEGER NOT NULL,
+priority INTEGER NOT NULL,samesite INTEGER NOT NULL,source_scheme INTEGER NOT NULL,
+source_port INTEGER NOT NULL,last_update_utc INTEGER NOT NULL,source_type INTEGER NOT NULL,
+has_cross_site_ancestor INTEGER NOT NULL)"""
+
+
+def filler(i):
+    return "filler%03d" % i, "v" * (i % 50), ".example%d.com" % i
+
+
+def gen_firefox():
+    for f in ("firefox.sqlite", "firefox.sqlite-wal"):
+        if os.path.exists(f):
+            os.remove(f)
+    db = sqlite3.connect("tmp.sqlite")
+    db.execute("PRAGMA page_size=1024")
+    db.execute(FF_SCHEMA)
+    rows = [
+        ("SESSDATA", "sess%2C1", ".bilibili.com", "/", 4102444800, 1, 1),
+        ("buvid3", "b3", "www.bilibili.com", "/", 4102444800000, 0, 0),  # milliseconds
+        ("long", "x" * 3000, ".bilibili.com", "/", 4102444800, 0, 0),  # overflow pages
+        ("other", "o", ".example.com", "/", 4102444800, 0, 0),
+    ]
+    for i in range(300):
+        n, v, h = filler(i)
+        rows.append((n, v, h, "/", 4102444800, 0, 0))
+    db.executemany("INSERT INTO moz_cookies (name, value, host, path, expiry, isSecure, isHttpOnly) "
+                   "VALUES (?, ?, ?, ?, ?, ?, ?)", rows)
+    db.commit()
+    db.execute("PRAGMA journal_mode=WAL")
+    db.execute("PRAGMA wal_autocheckpoint=0")
+    # only in wal file
+    db.execute("INSERT INTO moz_cookies (name, value, host, path, expiry, isSecure, isHttpOnly) "
+               "VALUES ('bili_jct', 'jct', '.bilibili.com', '/', 4102444800, 0, 0)")
+    db.commit()
+    shutil.copy("tmp.sqlite", "firefox.sqlite")
+    shutil.copy("tmp.sqlite-wal", "firefox.sqlite-wal")
+    db.close()
+    os.remove("tmp.sqlite")
+
+
+def encrypt(plain, password="peanuts"):
+    key = hashlib.pbkdf2_hmac("sha1", password.encode(), b"saltysalt", 1, 16)
+    out = subprocess.run(["openssl", "enc", "-aes-128-cbc", "-K", key.hex(), "-iv", "20" * 16],
+                         input=plain, capture_output=True, check=True).stdout
+    return b"v10" + out
+
+
+def gen_chromium(name, version):
+    if os.path.exists(name):
+        os.remove(name)
+    db = sqlite3.connect(name)
+    db.execute("PRAGMA page_size=1024")
+    db.execute("CREATE TABLE meta(key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR)")
+    db.execute("INSERT INTO meta VALUES ('version', ?)", (str(version),))
+    db.execute(CR_SCHEMA)
+    epoch = 11644473600 * 1000 * 1000
+
+    def row(host, name, value, enc, expires, secure, httponly):
+        prefix = hashlib.sha256(host.encode()).digest() if version >= 24 else b""
+        encrypted = encrypt(prefix + enc.encode()) if enc else b""
+        return (0, host, "", name, value, encrypted, "/", expires * 1000 * 1000 + epoch if expires else 0,
+                secure, httponly, 0, 1 if expires else 0, 1, 1, 0, 2, 443, 0, 0, 0)
+
+    rows = [
+        row(".bilibili.com", "SESSDATA", "", "sess,1", 4102444800, 1, 1),
+        row("www.bilibili.com", "buvid3", "plain", "", 0, 0, 0),
+        row(".example.com", "other", "", "o", 4102444800, 0, 0),
+    ]
+    for i in range(200):
+        n, v, h = filler(i)
+        rows.append(row(h, n, v, "", 4102444800, 0, 0))
+    db.executemany("INSERT INTO cookies VALUES (%s)" % ",".join("?" * 20), rows)
+    db.commit()
+    db.close()
+
+
+if __name__ == "__main__":
+    gen_firefox()
+    gen_chromium("chromium_v24.sqlite", 24)
+    gen_chromium("chromium_v21.sqlite", 21)
@@ -1,9 +1,8 @@
 package cookie
 
 import (
-	_ "embed"
+	"bytes"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"path"
@@ -12,12 +11,17 @@ import (
 	"unicode"
 
 	"github.com/apex/log"
-	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	cookieFile       = "cookies.json"
+	legacyCookieFile = "cookie.txt" // "k1=v1;k2=v2" saved by old versions
 )
 
 var (
-	jar        *cookiejar.Jar
+	jar        = NewJar()
 	biliUrl, _ = url.Parse("https://bilibili.com")
+	storeDir   string // directory cookies are loaded from and saved to
 )
 
 func ParseCookies(ckTxt string) []*http.Cookie {
@@ -46,58 +50,105 @@ func ParseCookies(ckTxt string) []*http.Cookie {
 	return cks
 }
 
-func init() {
-	jar, _ = cookiejar.New(&cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
-	})
+// ConfigDir return config directory, created if not exists
+func ConfigDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
 	configPath := path.Join(home, ".config", "bili-downloader")
 	_ = os.MkdirAll(configPath, 0755)
-	confFile := filepath.Join(configPath, "cookie.txt")
+	return configPath
+}
 
-	var ckTxt string
-	if _, err := os.Stat(confFile); err != nil {
-		if !os.IsNotExist(err) {
-			panic(err)
-		}
-	} else {
-		buf, err := os.ReadFile(confFile)
-		if err != nil {
-			panic(err)
+func init() {
+	if err := load(ConfigDir()); err != nil {
+		panic(err)
+	}
+}
+
+// Use switch cookie store to directory, cookies in jar are replaced by cookies saved in dir
+func Use(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	jar.Clear()
+	return load(dir)
+}
+
+// StoreDir return directory cookies are saved to
+func StoreDir() string {
+	return storeDir
+}
+
+func load(dir string) error {
+	storeDir = dir
+	confFile := filepath.Join(dir, cookieFile)
+	if _, err := os.Stat(confFile); err == nil {
+		if err := LoadFile(confFile); err != nil {
+			log.Errorf("load cookies from %v error: %v", confFile, err)
 		}
-		ckTxt = string(buf)
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
 	}
 
-	jar.SetCookies(biliUrl, ParseCookies(string(ckTxt)))
+	// migrate cookies saved by old versions
+	legacyFile := filepath.Join(dir, legacyCookieFile)
+	buf, err := os.ReadFile(legacyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	jar.SetCookies(biliUrl, ParseCookies(string(buf)))
+	return nil
 }
 
-// SaveCookies save cookies in jar to file
-func SaveCookies() {
-	home, err := os.UserHomeDir()
+// LoadFile load cookies from file into jar, json, netscape cookies.txt and
+// "k1=v1; k2=v2" are accepted, expired cookies are skipped
+func LoadFile(name string) error {
+	buf, err := os.ReadFile(name)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	configPath := path.Join(home, ".config", "bili-downloader")
-	_ = os.MkdirAll(configPath, 0755)
-	confFile := filepath.Join(configPath, "cookie.txt")
-	of, err := os.OpenFile(confFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	cks, err := ReadAny(buf)
 	if err != nil {
-		log.Errorf("save cookie open file for write error: %v", err)
-		return
+		return err
 	}
-	defer func() {
-		of.Sync()
-		of.Close()
-	}()
-	// jar support concurrent access
-	for _, k := range jar.Cookies(biliUrl) {
-		of.WriteString(k.String() + ";")
+	log.Debugf("load %v cookies from %v", jar.Add(cks), name)
+	return nil
+}
+
+// ExportFile export cookies in jar to file, format is json or netscape
+func ExportFile(name, format string) error {
+	buf := bytes.NewBuffer(nil)
+	var err error
+	if format == "netscape" {
+		err = WriteNetscape(buf, jar.All())
+	} else {
+		err = WriteJSON(buf, jar.All())
 	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, buf.Bytes(), 0600)
+}
+
+// SaveCookies save cookies in jar to file
+func SaveCookies() {
+	confFile := filepath.Join(storeDir, cookieFile)
+	if err := ExportFile(confFile, "json"); err != nil {
+		log.Errorf("save cookies to %v error: %v", confFile, err)
+	}
+}
+
+// SetCookies save cookies of bilibili into jar
+func SetCookies(cks []*http.Cookie) {
+	jar.SetCookies(biliUrl, cks)
 }
 
-func GetCookieJar() *cookiejar.Jar {
+func GetCookieJar() *Jar {
 	return jar
 }
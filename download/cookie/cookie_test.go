@@ -0,0 +1,128 @@
+package cookie
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJar(t *testing.T) {
+	j := NewJar()
+	u, _ := url.Parse("https://passport.bilibili.com/x/passport-login/web/qrcode/poll")
+	expires := time.Unix(4102444800, 0)
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "SESSDATA", Value: "sess", Domain: ".bilibili.com", Path: "/", Expires: expires, Secure: true, HttpOnly: true},
+		{Name: "host", Value: "only"},
+		{Name: "gone", Value: "x", Domain: "bilibili.com", MaxAge: -1},
+	})
+
+	cks := j.All()
+	require.Len(t, cks, 2)
+	require.Equal(t, &Cookie{
+		Name:     "SESSDATA",
+		Value:    "sess",
+		Domain:   "bilibili.com",
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+	}, cks[0])
+	require.Equal(t, &Cookie{
+		Name:     "host",
+		Value:    "only",
+		Domain:   "passport.bilibili.com",
+		HostOnly: true,
+		Path:     "/x/passport-login/web/qrcode",
+	}, cks[1])
+
+	// restored jar matches the same urls
+	j2 := NewJar()
+	require.Equal(t, 2, j2.Add(cks))
+	api, _ := url.Parse("https://api.bilibili.com/x/web-interface/nav")
+	require.Len(t, j2.Cookies(api), 1)
+	require.Len(t, j2.Cookies(u), 2)
+	require.Equal(t, cks, j2.All())
+
+	// expired cookies are skipped
+	require.Equal(t, 0, NewJar().Add([]*Cookie{{Name: "old", Value: "v", Domain: "bilibili.com", Path: "/", Expires: time.Unix(1, 0)}}))
+}
+
+func TestJarOtherDomain(t *testing.T) {
+	j := NewJar()
+	cdn, _ := url.Parse("https://upos-sz-mirrorcos.bilivideo.com/upgcxcode/a.flv")
+	j.SetCookies(cdn, []*http.Cookie{
+		{Name: "SESSDATA", Value: "evil", Domain: ".bilibili.com", Path: "/"},
+		{Name: "suffix", Value: "evil", Domain: "com", Path: "/"},
+		{Name: "cdn", Value: "ok", Domain: ".bilivideo.com", Path: "/"},
+	})
+	ip, _ := url.Parse("http://127.0.0.1:8080/")
+	j.SetCookies(ip, []*http.Cookie{
+		{Name: "other", Value: "evil", Domain: "127.0.0.2"},
+		{Name: "local", Value: "ok", Domain: "127.0.0.1"},
+	})
+
+	cks := j.All()
+	require.Len(t, cks, 2)
+	require.Equal(t, "local", cks[0].Name)
+	require.True(t, cks[0].HostOnly)
+	require.Equal(t, "cdn", cks[1].Name)
+	require.Equal(t, "bilivideo.com", cks[1].Domain)
+	api, _ := url.Parse("https://api.bilibili.com/x/web-interface/nav")
+	require.Len(t, j.Cookies(api), 0)
+}
+
+func TestFormats(t *testing.T) {
+	cks := []*Cookie{
+		{Name: "SESSDATA", Value: "sess", Domain: "bilibili.com", Path: "/", Expires: time.Unix(4102444800, 0), Secure: true, HttpOnly: true},
+		{Name: "buvid3", Value: "b3", Domain: "www.bilibili.com", HostOnly: true, Path: "/"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, WriteNetscape(buf, cks))
+	require.Equal(t, "# Netscape HTTP Cookie File\n\n"+
+		"#HttpOnly_.bilibili.com\tTRUE\t/\tTRUE\t4102444800\tSESSDATA\tsess\n"+
+		"www.bilibili.com\tFALSE\t/\tFALSE\t0\tbuvid3\tb3\n", buf.String())
+	got, err := ReadAny(buf.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, cks, got)
+
+	buf.Reset()
+	require.Nil(t, WriteJSON(buf, cks))
+	got, err = ReadAny(buf.Bytes())
+	require.Nil(t, err)
+	for i := range cks {
+		require.True(t, cks[i].Expires.Equal(got[i].Expires))
+		got[i].Expires = cks[i].Expires
+	}
+	require.Equal(t, cks, got)
+
+	got, err = ReadAny([]byte("SESSDATA=sess; bili_jct=\"jct\""))
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "jct", got[1].Value)
+
+	_, err = ReadNetscape(strings.NewReader(".bilibili.com\tTRUE\t/\n"))
+	require.NotNil(t, err)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "cookies.json")
+	require.Nil(t, os.WriteFile(name, []byte("old"), 0644))
+	require.Nil(t, writeFileAtomic(name, []byte("new"), 0600))
+
+	st, err := os.Stat(name)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	buf, _ := os.ReadFile(name)
+	require.Equal(t, "new", string(buf))
+
+	entries, _ := os.ReadDir(filepath.Dir(name))
+	require.Len(t, entries, 1)
+}
@@ -0,0 +1,166 @@
+package cookie
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+const netscapeHeader = "# Netscape HTTP Cookie File"
+
+// ReadJSON read cookies saved by WriteJSON
+func ReadJSON(r io.Reader) ([]*Cookie, error) {
+	var cks []*Cookie
+	if err := json.NewDecoder(r).Decode(&cks); err != nil {
+		return nil, err
+	}
+	return cks, nil
+}
+
+// WriteJSON write cookies as json array
+func WriteJSON(w io.Writer, cks []*Cookie) error {
+	buf, err := json.MarshalIndent(cks, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+// ReadNetscape read cookies.txt used by curl, wget and browser extensions
+func ReadNetscape(r io.Reader) ([]*Cookie, error) {
+	var (
+		cks []*Cookie
+		sc  = bufio.NewScanner(r)
+		no  = 0
+	)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		no++
+		line := strings.TrimRight(sc.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %v: expect 7 fields, got %v", no, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: invalid expires: %v", no, err)
+		}
+		c := &Cookie{
+			Domain:   strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cks = append(cks, c)
+	}
+	return cks, sc.Err()
+}
+
+// WriteNetscape write cookies as netscape cookies.txt
+func WriteNetscape(w io.Writer, cks []*Cookie) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(netscapeHeader + "\n\n")
+	for _, c := range cks {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, boolStr(!c.HostOnly), c.Path, boolStr(c.Secure), expires, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+func boolStr(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// ReadAny detect format of cookie file: json, netscape cookies.txt or
+// "k1=v1; k2=v2" copied from browser devtools
+func ReadAny(buf []byte) ([]*Cookie, error) {
+	trimmed := bytes.TrimSpace(buf)
+	switch {
+	case len(trimmed) == 0:
+		return nil, nil
+	case trimmed[0] == '[':
+		return ReadJSON(bytes.NewReader(trimmed))
+	case bytes.HasPrefix(trimmed, []byte("# Netscape")) || bytes.HasPrefix(trimmed, []byte("# HTTP Cookie File")) ||
+		bytes.Contains(trimmed, []byte("\t")):
+		return ReadNetscape(bytes.NewReader(trimmed))
+	}
+	var cks []*Cookie
+	for _, ck := range ParseCookies(string(trimmed)) {
+		cks = append(cks, &Cookie{
+			Name:   ck.Name,
+			Value:  ck.Value,
+			Domain: "bilibili.com",
+			Path:   "/",
+			Secure: ck.Secure,
+		})
+	}
+	return cks, nil
+}
+
+// writeFileAtomic write file by renaming a temp file, so the file is never half written
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(name)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
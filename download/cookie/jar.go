@@ -0,0 +1,213 @@
+package cookie
+
+import (
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Cookie is a cookie with every attribute kept for persistence
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"` // host name without leading dot
+	HostOnly bool      `json:"host_only"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires"` // zero for session cookie
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+}
+
+func (c *Cookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// Expired report if cookie expired at now
+func (c *Cookie) Expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// Jar is a http.CookieJar which records every cookie it accepted, so they
+// can be persisted with expiry, domain and path, cookie matching is done by
+// net/http/cookiejar
+type Jar struct {
+	mu    sync.Mutex
+	inner *cookiejar.Jar
+	store map[string]*Cookie
+}
+
+// NewJar create an empty jar
+func NewJar() *Jar {
+	inner, _ := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	return &Jar{
+		inner: inner,
+		store: map[string]*Cookie{},
+	}
+}
+
+// Cookies implement http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.inner.Cookies(u)
+}
+
+// SetCookies implement http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cks []*http.Cookie) {
+	j.set(u, cks)
+}
+
+// set set cookies into jar and return number of cookies accepted, cookies
+// rejected by cookiejar like those of other domains are not recorded
+func (j *Jar) set(u *url.URL, cks []*http.Cookie) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return 0
+	}
+	j.inner.SetCookies(u, cks)
+
+	var (
+		now      = time.Now()
+		host     = strings.ToLower(u.Hostname())
+		accepted = 0
+	)
+	for _, ck := range cks {
+		domain, hostOnly, ok := cookieDomain(host, ck.Domain)
+		if !ok {
+			continue
+		}
+		c := &Cookie{
+			Name:     ck.Name,
+			Value:    ck.Value,
+			Domain:   domain,
+			HostOnly: hostOnly,
+			Path:     ck.Path,
+			Secure:   ck.Secure,
+			HttpOnly: ck.HttpOnly,
+		}
+		if c.Path == "" || c.Path[0] != '/' {
+			c.Path = defaultPath(u.Path)
+		}
+		switch {
+		case ck.MaxAge < 0:
+			c.Expires = now
+		case ck.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(ck.MaxAge) * time.Second)
+		case !ck.Expires.IsZero():
+			c.Expires = ck.Expires
+		}
+		accepted++
+		if c.Expired(now) {
+			delete(j.store, c.key())
+			continue
+		}
+		j.store[c.key()] = c
+	}
+	return accepted
+}
+
+// cookieDomain check domain attribute of cookie set by host as cookiejar does,
+// RFC 6265 section 5.3, domain of cookie is returned if it's accepted
+func cookieDomain(host, attr string) (domain string, hostOnly bool, ok bool) {
+	if attr == "" {
+		return host, true, true
+	}
+	domain = strings.TrimPrefix(strings.ToLower(attr), ".")
+	if domain == "" || strings.HasSuffix(domain, ".") {
+		return "", false, false
+	}
+	if net.ParseIP(host) != nil {
+		// ip address only set cookies of itself
+		return host, true, domain == host
+	}
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		// cookies of public suffix like com are host only of the suffix itself
+		return host, true, domain == host
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// defaultPath is default cookie path of request path, RFC 6265 section 5.1.4
+func defaultPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// Add add persisted cookies into jar, expired cookies are skipped
+func (j *Jar) Add(cks []*Cookie) int {
+	now := time.Now()
+	added := 0
+	for _, c := range cks {
+		if c.Expired(now) || c.Name == "" || c.Domain == "" {
+			continue
+		}
+		u := &url.URL{
+			Scheme: "https",
+			Host:   strings.TrimPrefix(c.Domain, "."),
+			Path:   c.Path,
+		}
+		ck := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if !c.HostOnly {
+			ck.Domain = "." + strings.TrimPrefix(c.Domain, ".")
+		}
+		added += j.set(u, []*http.Cookie{ck})
+	}
+	return added
+}
+
+// All return unexpired cookies in jar sorted by domain, path and name
+func (j *Jar) All() []*Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	cks := make([]*Cookie, 0, len(j.store))
+	for k, c := range j.store {
+		if c.Expired(now) {
+			delete(j.store, k)
+			continue
+		}
+		cp := *c
+		cks = append(cks, &cp)
+	}
+	sort.Slice(cks, func(a, b int) bool {
+		return cks[a].key() < cks[b].key()
+	})
+	return cks
+}
+
+// Clear remove every cookie in jar
+func (j *Jar) Clear() {
+	inner, _ := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.inner = inner
+	j.store = map[string]*Cookie{}
+}
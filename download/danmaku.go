@@ -0,0 +1,49 @@
+package download
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download/httpcli"
+)
+
+const (
+	kDanmakuUrl = "https://api.bilibili.com/x/v1/dm/list.so"
+)
+
+// GetDanmakuXML get danmaku xml of video cid
+func GetDanmakuXML(cid int64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, kDanmakuUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	// transport only decompress gzip automatically
+	req.Header.Add("accept-encoding", "deflate")
+	q := req.URL.Query()
+	q.Set("oid", strconv.FormatInt(cid, 10))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := httpcli.Inst.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Errorf("status code invalid: %v", resp.StatusCode)
+		return nil, fmt.Errorf("invalid status code %v", resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if resp.Header.Get("content-encoding") == "deflate" {
+		fr := flate.NewReader(resp.Body)
+		defer fr.Close()
+		body = fr
+	}
+
+	return io.ReadAll(body)
+}
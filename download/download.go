@@ -12,6 +12,7 @@ import (
 	"github.com/apex/log"
 	"github.com/rammiah/bili-downloader/consts"
 	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/rammiah/bili-downloader/metrics"
 	"github.com/tidwall/gjson"
 )
 
@@ -24,14 +25,15 @@ type DownloadInfo struct {
 	Size    int64  `json:"size"`   // 文件大小
 	Url     string `json:"url"`
 	Format  string `json:"format"`
-}
+	MD5     string `json:"md5,omitempty"` // md5 of file given by playurl, empty if not given
 
-func GetDownloadInfoByAidCid(videoId string, avid, cid int64) (*DownloadInfo, error) {
-	const (
-		QUrl = "https://api.bilibili.com/x/player/playurl"
-	)
+	AcceptQuality []int64 `json:"accept_quality,omitempty"` // qualities of video, best first
+	Downgraded    bool    `json:"downgraded,omitempty"`     // got worse quality than requested though video has it
+}
 
-	req, err := http.NewRequest(http.MethodGet, QUrl, nil)
+// GetDownloadInfoByAidCid get download info of ugc video with quality qn
+func GetDownloadInfoByAidCid(videoId string, avid, cid, qn int64) (*DownloadInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, kUrlUrl, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +42,7 @@ func GetDownloadInfoByAidCid(videoId string, avid, cid int64) (*DownloadInfo, er
 		"avid":  strconv.FormatInt(avid, 10),
 		"cid":   strconv.FormatInt(cid, 10),
 		"otype": "json",
-		"qn":    "125", // 画质直接按照最高获取
+		"qn":    strconv.FormatInt(qn, 10),
 		"fourk": "1",
 		"fnver": "0",
 		"fnval": "0",
@@ -54,30 +56,35 @@ func GetDownloadInfoByAidCid(videoId string, avid, cid int64) (*DownloadInfo, er
 	log.Debugf("query params: %v", q.Encode())
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := httpcli.Inst.Do(req)
+	buf, err := doApiRequest(req)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		log.Errorf("status code invalid: %v", resp.StatusCode)
-		return nil, fmt.Errorf("invalid status code %v", resp.StatusCode)
-	}
-
-	buf, err := io.ReadAll(resp.Body)
+	info, err := parsePlayUrl(gjson.GetBytes(buf, "data"))
 	if err != nil {
 		return nil, err
 	}
+	info.VideoID = videoId
+	info.Avid = avid
+	info.Cid = cid
+	checkDowngrade(qn, maxQuality(), info)
 
-	code := gjson.GetBytes(buf, "code")
-	if code.Type == gjson.Null {
-		return nil, errors.New("null json")
-	} else if code.Int() != 0 {
-		return nil, fmt.Errorf("code not 0: %v", code.Int())
+	return info, nil
+}
+
+// GetDownloadInfo get download info of video with quality qn, ugc and bangumi are both supported.
+// warning is logged if account can't get qn or playurl downgrades quality
+func GetDownloadInfo(video *VideoInfo, qn int64) (*DownloadInfo, error) {
+	checkQuality(qn)
+	if video.IsPGC() {
+		return GetDownloadInfoByEpCid(video.VideoID, video.EpID, video.Avid, video.Cid, qn)
 	}
+	return GetDownloadInfoByAidCid(video.VideoID, video.Avid, video.Cid, qn)
+}
 
-	data := gjson.GetBytes(buf, "data")
+// parsePlayUrl parse data part of playurl response
+func parsePlayUrl(data gjson.Result) (*DownloadInfo, error) {
 	durl := data.Get("durl")
 
 	if len(durl.Array()) == 0 {
@@ -89,22 +96,27 @@ func GetDownloadInfoByAidCid(videoId string, avid, cid int64) (*DownloadInfo, er
 		length = obj.Get("length").Int()
 		size   = obj.Get("size").Int()
 		u      = obj.Get("url").String()
+		sum    = obj.Get("md5").String()
 		qn     = data.Get("quality").Int()
 		format = data.Get("format").String()
+		accept []int64
 	)
+	for _, q := range data.Get("accept_quality").Array() {
+		accept = append(accept, q.Int())
+	}
 	if v, ok := consts.FormatBiliToFile[format]; ok {
 		format = v
 	}
 
 	return &DownloadInfo{
-		VideoID: videoId,
-		Avid:    avid,
-		Cid:     cid,
-		Qn:      qn,
-		Length:  length,
-		Size:    size,
-		Url:     u,
-		Format:  format,
+		Qn:     qn,
+		Length: length,
+		Size:   size,
+		Url:    u,
+		Format: format,
+		MD5:    sum,
+
+		AcceptQuality: accept,
 	}, nil
 }
 
@@ -146,3 +158,90 @@ func authVideo(videoId, videoUrl string) error {
 
 	return nil
 }
+
+// doApiRequest do request to api which response with code/message/data json,
+// requests to web apis are signed with wbi and retried once with new keys if rejected
+func doApiRequest(req *http.Request) ([]byte, error) {
+	if !needWbi(req) {
+		return doApiRequestOnce(req)
+	}
+
+	query := req.URL.RawQuery
+	var (
+		buf []byte
+		err error
+	)
+	for i := 0; i < 2; i++ {
+		req.URL.RawQuery = query
+		if err := signRequest(req); err != nil {
+			return nil, err
+		}
+		buf, err = doApiRequestOnce(req)
+		if err == nil {
+			return buf, nil
+		}
+		code := gjson.GetBytes(buf, "code").Int()
+		if code != codeWbiForbidden && code != codeWbiRisk {
+			break
+		}
+		log.Warnf("wbi signature rejected with code %v, refresh keys", code)
+		wbiKeys.expire()
+	}
+	return nil, err
+}
+
+// doApiRequestOnce do request, body is returned with api error for checking code
+func doApiRequestOnce(req *http.Request) ([]byte, error) {
+	log.Debugf("api request %v", req.URL.String())
+	start := time.Now()
+	resp, err := httpcli.Inst.Do(req)
+	if err != nil {
+		observeApi(req, start, nil)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := readApiResponse(resp)
+	observeApi(req, start, buf)
+	if err != nil {
+		return nil, err
+	}
+	if gjson.GetBytes(buf, "code").Int() != 0 {
+		return buf, apiError(buf)
+	}
+
+	return buf, nil
+}
+
+// observeApi record result of api request in metrics, buf is nil if no json is got
+func observeApi(req *http.Request, start time.Time, buf []byte) {
+	code := "error"
+	if buf != nil {
+		code = strconv.FormatInt(gjson.GetBytes(buf, "code").Int(), 10)
+	}
+	metrics.APIRequests.WithLabelValues(req.URL.Path, code).Inc()
+	metrics.APIDuration.WithLabelValues(req.URL.Path).Observe(time.Since(start).Seconds())
+}
+
+// readApiResponse check status code and read json body
+func readApiResponse(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusOK {
+		log.Errorf("status code invalid: %v", resp.StatusCode)
+		return nil, fmt.Errorf("invalid status code %v", resp.StatusCode)
+	}
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if gjson.GetBytes(buf, "code").Type == gjson.Null {
+		return nil, errors.New("null json")
+	}
+
+	return buf, nil
+}
+
+// apiError build error from code and message of response
+func apiError(buf []byte) error {
+	return fmt.Errorf("code not 0: %v, message: %v", gjson.GetBytes(buf, "code").Int(), gjson.GetBytes(buf, "message").String())
+}
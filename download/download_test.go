@@ -2,13 +2,19 @@ package download
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync/atomic"
 	"testing"
 
 	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rammiah/bili-downloader/consts"
 	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/metrics"
 	"github.com/rammiah/bili-downloader/utils"
 	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
 )
 
 const (
@@ -25,7 +31,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestGetDownloadInfoByAidCid(t *testing.T) {
-	info, err := GetDownloadInfoByAidCid(VideoID, Avid, Cid)
+	info, err := GetDownloadInfoByAidCid(VideoID, Avid, Cid, consts.QnBest)
 	require.Nil(t, err)
 	require.NotNil(t, info)
 	require.EqualValues(t, 80, info.Qn)
@@ -50,7 +56,7 @@ func (wc *WriteCounter) Reset() int64 {
 }
 
 // func TestDownloadVideo(t *testing.T) {
-//     info, err := GetDownloadInfoByAidCid(VideoID, Avid, Cid)
+//     info, err := GetDownloadInfoByAidCid(VideoID, Avid, Cid, consts.QnBest)
 //     log.Infof("video %v info %v", VideoID, utils.Json(info))
 //     require.Nil(t, err)
 //     err = authVideo(VideoID, info.Url)
@@ -63,7 +69,7 @@ func (wc *WriteCounter) Reset() int64 {
 // }
 
 func TestAuthVideo(t *testing.T) {
-	info, err := GetDownloadInfoByAidCid(VideoID, Avid, Cid)
+	info, err := GetDownloadInfoByAidCid(VideoID, Avid, Cid, consts.QnBest)
 	require.Nil(t, err)
 	err = authVideo(VideoID, info.Url)
 	require.Nil(t, err)
@@ -71,3 +77,50 @@ func TestAuthVideo(t *testing.T) {
 		log.Errorf("auth error: %v", err)
 	}
 }
+
+func TestParsePlayUrl(t *testing.T) {
+	info, err := parsePlayUrl(gjson.Parse(`{
+		"quality": 64, "format": "flv720",
+		"accept_quality": [120, 116, 80, 64, 32, 16],
+		"durl": [{"length": 1000, "size": 2000, "url": "https://example.com/1.flv", "md5": "2a65e65f2db52d8ddc163c3d7c6cd7b2"}]
+	}`))
+	require.Nil(t, err)
+	require.Equal(t, &DownloadInfo{
+		Qn:            64,
+		Length:        1000,
+		Size:          2000,
+		Url:           "https://example.com/1.flv",
+		Format:        "flv",
+		MD5:           "2a65e65f2db52d8ddc163c3d7c6cd7b2",
+		AcceptQuality: []int64{120, 116, 80, 64, 32, 16},
+	}, info)
+
+	// md5 is not always given
+	info, err = parsePlayUrl(gjson.Parse(`{"quality": 80, "format": "mp4", "durl": [{"size": 2000, "url": "https://example.com/1.mp4"}]}`))
+	require.Nil(t, err)
+	require.Equal(t, "mp4", info.Format)
+	require.Empty(t, info.MD5)
+
+	_, err = parsePlayUrl(gjson.Parse(`{"quality": 80, "durl": []}`))
+	require.NotNil(t, err)
+}
+
+func TestDoApiRequestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"code": -404, "message": "啥都木有"}`))
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/test", "/test", "/gone"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.Nil(t, err)
+		_, err = doApiRequest(req)
+		require.NotNil(t, err)
+	}
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.APIRequests.WithLabelValues("/test", "-404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.APIRequests.WithLabelValues("/gone", "error")))
+}
@@ -0,0 +1,78 @@
+package download
+
+import (
+	"time"
+)
+
+// EventType type of download event
+type EventType string
+
+const (
+	EventStart        EventType = "start"
+	EventFragmentDone EventType = "fragment_done"
+	EventProgress     EventType = "progress"
+	EventRetry        EventType = "retry"
+	EventError        EventType = "error"
+	EventComplete     EventType = "complete"
+)
+
+// progressInterval interval of progress events
+const progressInterval = 500 * time.Millisecond
+
+// Event happened when downloading, fields not related to type are zero
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Fragment  int           // index of fragment, for fragment_done and retry
+	Fragments int           // count of fragments, for start
+	Bytes     int64         // bytes of fragment, for fragment_done
+	Elapsed   time.Duration // time used by fragment for fragment_done, by download for complete
+	Attempt   int           // failed attempts of fragment, for retry
+	Err       error         // for retry and error
+	Done      int64         // bytes downloaded
+	Total     int64         // size of file
+}
+
+// SetEventHandler receive events of download with h, it's an observer added to downloader,
+// h is called from worker goroutines, and must be fast
+func (d *VideoDownloader) SetEventHandler(h func(e *Event)) *VideoDownloader {
+	return d.AddObserver(&eventObserver{d: d, h: h})
+}
+
+// eventObserver turn notifications to events
+type eventObserver struct {
+	NopObserver
+	d *VideoDownloader
+	h func(e *Event)
+}
+
+func (o *eventObserver) emit(e *Event) {
+	e.Time = time.Now()
+	e.Done = o.d.pg.Done()
+	e.Total = o.d.downInfo.Size
+	o.h(e)
+}
+
+func (o *eventObserver) OnStart(info *DownloadInfo, fragments int) {
+	o.emit(&Event{Type: EventStart, Fragments: fragments})
+}
+
+func (o *eventObserver) OnFragmentDone(idx int, bytes int64, elapsed time.Duration) {
+	o.emit(&Event{Type: EventFragmentDone, Fragment: idx, Bytes: bytes, Elapsed: elapsed})
+}
+
+func (o *eventObserver) OnRetry(idx int, attempt int, err error) {
+	o.emit(&Event{Type: EventRetry, Fragment: idx, Attempt: attempt, Err: err})
+}
+
+func (o *eventObserver) OnProgress(done, total int64) {
+	o.emit(&Event{Type: EventProgress})
+}
+
+func (o *eventObserver) OnComplete(elapsed time.Duration) {
+	o.emit(&Event{Type: EventComplete, Elapsed: elapsed})
+}
+
+func (o *eventObserver) OnError(err error) {
+	o.emit(&Event{Type: EventError, Err: err})
+}
@@ -0,0 +1,124 @@
+package httpcli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Direct used as proxy to connect without proxy
+const Direct = "direct"
+
+// ProxyConfig proxies of requests, empty means use Default, empty Default means use env vars.
+// url schemes http, https, socks5 and socks5h are supported, user info in url is used for auth
+type ProxyConfig struct {
+	Default string // proxy for hosts not api or cdn
+	API     string // proxy for bilibili web apis and pages
+	CDN     string // proxy for video and image cdn
+}
+
+type hostKind int
+
+const (
+	hostOther hostKind = iota
+	hostAPI
+	hostCDN
+)
+
+var (
+	apiDomains = []string{"bilibili.com", "bilibili.tv", "biliapi.net"}
+	cdnDomains = []string{"bilivideo.com", "bilivideo.cn", "akamaized.net", "hdslb.com", "szbdyd.com", "bilicdn1.com"}
+)
+
+func matchDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// kindOf classify host, cdn checked first as some cdn hosts are under api domains
+func kindOf(host string) hostKind {
+	host = strings.ToLower(host)
+	switch {
+	case matchDomain(host, cdnDomains), strings.HasPrefix(host, "upos-"), strings.Contains(host, ".mcdn."):
+		return hostCDN
+	case matchDomain(host, apiDomains):
+		return hostAPI
+	default:
+		return hostOther
+	}
+}
+
+// parseProxy parse proxy url, nil returned for direct connection
+func parseProxy(s string) (*url.URL, error) {
+	if s == Direct {
+		return nil, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %v: %w", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		// host names are always resolved by socks5 proxy in net/http
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %v", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy host missing: %v", s)
+	}
+	return u, nil
+}
+
+// proxyFunc build proxy func for http.Transport
+func (c ProxyConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	routes := map[hostKind]func(*http.Request) (*url.URL, error){}
+	for kind, s := range map[hostKind]string{
+		hostOther: c.Default,
+		hostAPI:   c.API,
+		hostCDN:   c.CDN,
+	} {
+		if s == "" {
+			continue
+		}
+		u, err := parseProxy(s)
+		if err != nil {
+			return nil, err
+		}
+		routes[kind] = func(*http.Request) (*url.URL, error) {
+			return u, nil
+		}
+	}
+	def, ok := routes[hostOther]
+	if !ok {
+		def = http.ProxyFromEnvironment
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if f, ok := routes[kindOf(req.URL.Hostname())]; ok {
+			return f(req)
+		}
+		return def(req)
+	}, nil
+}
+
+// SetProxy route requests of Inst by conf
+func SetProxy(conf ProxyConfig) error {
+	proxy, err := conf.proxyFunc()
+	if err != nil {
+		return err
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = proxy
+	Inst.Transport = tr
+	return nil
+}
@@ -0,0 +1,183 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	kTagUrl = "https://api.bilibili.com/x/tag/archive/tags"
+)
+
+// VideoStat is statistics of video when queried
+type VideoStat struct {
+	View     int64 `json:"view"`
+	Danmaku  int64 `json:"danmaku"`
+	Reply    int64 `json:"reply"`
+	Favorite int64 `json:"favorite"`
+	Coin     int64 `json:"coin"`
+	Share    int64 `json:"share"`
+	Like     int64 `json:"like"`
+}
+
+// VideoMeta is metadata shared by every page of a video
+type VideoMeta struct {
+	Bvid        string    `json:"bvid,omitempty"`
+	Avid        int64     `json:"avid"`
+	SeasonID    int64     `json:"season_id,omitempty"` // bangumi season id
+	Title       string    `json:"title"`
+	Uploader    string    `json:"uploader"`
+	Mid         int64     `json:"mid"`     // uploader id
+	Pubdate     int64     `json:"pubdate"` // unix timestamp in seconds
+	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`
+	Cover       string    `json:"cover"` // cover image url
+	Tname       string    `json:"tname"` // category name
+	Stat        VideoStat `json:"stat"`
+}
+
+// PubTime return publish time of video
+func (m *VideoMeta) PubTime() time.Time {
+	return time.Unix(m.Pubdate, 0)
+}
+
+// parseVideoMeta parse metadata from __INITIAL_STATE__ of video page
+func parseVideoMeta(state gjson.Result) *VideoMeta {
+	vd := state.Get("videoData")
+	meta := &VideoMeta{
+		Bvid:        vd.Get("bvid").String(),
+		Avid:        vd.Get("aid").Int(),
+		Title:       vd.Get("title").String(),
+		Uploader:    vd.Get("owner.name").String(),
+		Mid:         vd.Get("owner.mid").Int(),
+		Pubdate:     vd.Get("pubdate").Int(),
+		Description: vd.Get("desc").String(),
+		Cover:       httpsUrl(vd.Get("pic").String()),
+		Tname:       vd.Get("tname").String(),
+		Stat: VideoStat{
+			View:     vd.Get("stat.view").Int(),
+			Danmaku:  vd.Get("stat.danmaku").Int(),
+			Reply:    vd.Get("stat.reply").Int(),
+			Favorite: vd.Get("stat.favorite").Int(),
+			Coin:     vd.Get("stat.coin").Int(),
+			Share:    vd.Get("stat.share").Int(),
+			Like:     vd.Get("stat.like").Int(),
+		},
+	}
+	if meta.Avid == 0 {
+		meta.Avid = state.Get("aid").Int()
+	}
+	for _, tag := range state.Get("tags").Array() {
+		if name := tag.Get("tag_name").String(); name != "" {
+			meta.Tags = append(meta.Tags, name)
+		}
+	}
+	return meta
+}
+
+// seasonTypes map season type to category name
+var seasonTypes = map[int64]string{
+	1: "番剧",
+	2: "电影",
+	3: "纪录片",
+	4: "国创",
+	5: "电视剧",
+	7: "综艺",
+}
+
+// parseSeasonMeta parse metadata from pgc season api result
+func parseSeasonMeta(result gjson.Result) *VideoMeta {
+	meta := &VideoMeta{
+		SeasonID:    result.Get("season_id").Int(),
+		Title:       result.Get("season_title").String(),
+		Uploader:    result.Get("up_info.uname").String(),
+		Mid:         result.Get("up_info.mid").Int(),
+		Description: result.Get("evaluate").String(),
+		Cover:       httpsUrl(result.Get("cover").String()),
+		Tname:       seasonTypes[result.Get("type").Int()],
+		Stat: VideoStat{
+			View:     result.Get("stat.views").Int(),
+			Danmaku:  result.Get("stat.danmakus").Int(),
+			Reply:    result.Get("stat.reply").Int(),
+			Favorite: result.Get("stat.favorites").Int(),
+			Coin:     result.Get("stat.coins").Int(),
+			Share:    result.Get("stat.share").Int(),
+			Like:     result.Get("stat.likes").Int(),
+		},
+	}
+	if meta.Title == "" {
+		meta.Title = result.Get("title").String()
+	}
+	if pub, err := time.ParseInLocation("2006-01-02 15:04:05", result.Get("publish.pub_time").String(), consts.CST); err == nil {
+		meta.Pubdate = pub.Unix()
+	}
+	for _, style := range result.Get("styles").Array() {
+		if name := style.String(); name != "" {
+			meta.Tags = append(meta.Tags, name)
+		}
+	}
+	return meta
+}
+
+// getVideoTags get tags of video by api
+func getVideoTags(avid int64) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, kTagUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	q := req.URL.Query()
+	q.Set("aid", strconv.FormatInt(avid, 10))
+	req.URL.RawQuery = q.Encode()
+
+	buf, err := doApiRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, tag := range gjson.GetBytes(buf, "data").Array() {
+		if name := tag.Get("tag_name").String(); name != "" {
+			tags = append(tags, name)
+		}
+	}
+	log.Debugf("get %v tags of av%v", len(tags), avid)
+	return tags, nil
+}
+
+// httpsUrl complete protocol relative and http urls
+func httpsUrl(u string) string {
+	switch {
+	case len(u) > 2 && u[:2] == "//":
+		return "https:" + u
+	case len(u) > 7 && u[:7] == "http://":
+		return "https://" + u[7:]
+	}
+	return u
+}
+
+// GetImage get image like cover from bilibili image server
+func GetImage(u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, httpsUrl(u), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	req.Header.Add("referer", "https://www.bilibili.com/")
+	resp, err := httpcli.Inst.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code %v", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
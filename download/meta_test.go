@@ -0,0 +1,61 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseVideoMeta(t *testing.T) {
+	const state = `{
+		"aid": 891245009,
+		"videoData": {
+			"bvid": "BV1pP4y1b7iP",
+			"aid": 891245009,
+			"title": "title",
+			"pubdate": 1636300800,
+			"desc": "desc",
+			"pic": "http://i0.hdslb.com/bfs/archive/cover.jpg",
+			"tname": "日常",
+			"owner": {"mid": 1234, "name": "uploader"},
+			"stat": {"view": 10, "danmaku": 2, "reply": 3, "favorite": 4, "coin": 5, "share": 6, "like": 7}
+		},
+		"tags": [{"tag_name": "tag1"}, {"tag_name": "tag2"}]
+	}`
+	meta := parseVideoMeta(gjson.Parse(state))
+	require.Equal(t, &VideoMeta{
+		Bvid:        "BV1pP4y1b7iP",
+		Avid:        891245009,
+		Title:       "title",
+		Uploader:    "uploader",
+		Mid:         1234,
+		Pubdate:     1636300800,
+		Description: "desc",
+		Tags:        []string{"tag1", "tag2"},
+		Cover:       "https://i0.hdslb.com/bfs/archive/cover.jpg",
+		Tname:       "日常",
+		Stat:        VideoStat{View: 10, Danmaku: 2, Reply: 3, Favorite: 4, Coin: 5, Share: 6, Like: 7},
+	}, meta)
+}
+
+func TestParseSeasonMeta(t *testing.T) {
+	const result = `{
+		"season_id": 456,
+		"season_title": "season",
+		"evaluate": "evaluate",
+		"cover": "//i0.hdslb.com/bfs/bangumi/cover.png",
+		"type": 3,
+		"publish": {"pub_time": "2021-11-08 00:00:00"},
+		"up_info": {"mid": 1, "uname": "official"},
+		"styles": ["历史", "人文"],
+		"stat": {"views": 100, "danmakus": 20}
+	}`
+	meta := parseSeasonMeta(gjson.Parse(result))
+	require.EqualValues(t, 456, meta.SeasonID)
+	require.Equal(t, "纪录片", meta.Tname)
+	require.EqualValues(t, 1636300800, meta.Pubdate)
+	require.Equal(t, []string{"历史", "人文"}, meta.Tags)
+	require.Equal(t, "https://i0.hdslb.com/bfs/bangumi/cover.png", meta.Cover)
+	require.EqualValues(t, 100, meta.Stat.View)
+}
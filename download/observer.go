@@ -0,0 +1,64 @@
+package download
+
+import (
+	"time"
+)
+
+// Observer is notified of what happens when a file is downloaded, methods are called
+// from worker goroutines at the same time and should return fast
+type Observer interface {
+	// OnStart download of info begins, file is split to fragments
+	OnStart(info *DownloadInfo, fragments int)
+	// OnFragmentStart fragment idx begins, it's called once however many times it's retried
+	OnFragmentStart(idx int, frag *VideoFragment)
+	// OnFragmentDone fragment idx of bytes is written to file, elapsed includes retries
+	OnFragmentDone(idx int, bytes int64, elapsed time.Duration)
+	// OnRetry attempt of fragment idx failed with err and it will be downloaded again
+	OnRetry(idx int, attempt int, err error)
+	// OnProgress done of total bytes are downloaded, it's called every progressInterval and at the end
+	OnProgress(done, total int64)
+	// OnComplete file is downloaded
+	OnComplete(elapsed time.Duration)
+	// OnError download failed with err, OnComplete is not called then
+	OnError(err error)
+}
+
+// NopObserver does nothing, embed it to implement only methods needed
+type NopObserver struct{}
+
+func (NopObserver) OnStart(info *DownloadInfo, fragments int)                  {}
+func (NopObserver) OnFragmentStart(idx int, frag *VideoFragment)               {}
+func (NopObserver) OnFragmentDone(idx int, bytes int64, elapsed time.Duration) {}
+func (NopObserver) OnRetry(idx int, attempt int, err error)                    {}
+func (NopObserver) OnProgress(done, total int64)                               {}
+func (NopObserver) OnComplete(elapsed time.Duration)                           {}
+func (NopObserver) OnError(err error)                                          {}
+
+// AddObserver notify o of download, observers are notified in order they are added
+func (d *VideoDownloader) AddObserver(o Observer) *VideoDownloader {
+	d.observers = append(d.observers, o)
+	return d
+}
+
+// notifyProgress notify observers of bytes downloaded
+func (d *VideoDownloader) notifyProgress() {
+	done := d.pg.Done()
+	for _, o := range d.observers {
+		o.OnProgress(done, d.downInfo.Size)
+	}
+}
+
+// progressLoop notify progress until stop closed
+func (d *VideoDownloader) progressLoop(stop <-chan struct{}, exited chan<- struct{}) {
+	defer close(exited)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.notifyProgress()
+		case <-stop:
+			return
+		}
+	}
+}
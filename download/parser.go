@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -13,18 +15,68 @@ import (
 )
 
 const (
-	kVideoUrl = "https://www.bilibili.com/video/"
-	kUrlUrl   = "https://api.bilibili.com/x/player/playurl"
+	kVideoUrl   = "https://www.bilibili.com/video/"
+	kBangumiUrl = "https://www.bilibili.com/bangumi/play/"
+	kUrlUrl     = "https://api.bilibili.com/x/player/wbi/playurl"
 )
 
+// videoPageUrl return the web page of video id, used as referer
+func videoPageUrl(videoId string) string {
+	if isPGCId(videoId) {
+		return kBangumiUrl + videoId
+	}
+	return kVideoUrl + videoId
+}
+
+// ParseId get video id from id or url of video page like https://www.bilibili.com/video/BV1pP4y1b7iP?p=2,
+// https://www.bilibili.com/bangumi/play/ep123 or https://www.bilibili.com/bangumi/media/md123
+func ParseId(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	if !strings.Contains(s, "/") {
+		return s, checkId(s)
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("parse url %v error: %w", s, err)
+	}
+	host := u.Hostname()
+	if host != "bilibili.com" && !strings.HasSuffix(host, ".bilibili.com") {
+		return "", fmt.Errorf("unsupported url %v, should be video or bangumi page of bilibili.com", s)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "video":
+		return parts[1], checkId(parts[1])
+	case len(parts) >= 3 && parts[0] == "bangumi" && (parts[1] == "play" || parts[1] == "media"):
+		return parts[2], checkId(parts[2])
+	}
+	return "", fmt.Errorf("no video id in url %v", s)
+}
+
 type VideoInfo struct {
-	VideoID  string `json:"video_id"` // video id, av/BV
+	VideoID  string `json:"video_id"` // video id, av/BV/ep/ss/md
 	Avid     int64  `json:"avid"`
 	Cid      int64  `json:"cid"`
+	EpID     int64  `json:"ep_id,omitempty"` // bangumi episode id, 0 for ugc videos
 	Title    string `json:"title"`
-	Page     int64  `json:"page"`      // page no
+	Page     int64  `json:"page"`      // page no, episode no for bangumi
 	Duration int64  `json:"duration"`  // length in seconds
 	PartName string `json:"part_name"` // part name
+	// first frame of page, episode cover for bangumi
+	FirstFrame string `json:"first_frame,omitempty"`
+
+	Meta *VideoMeta `json:"meta,omitempty"` // shared by pages of the same video
+}
+
+// IsPGC report if video is a bangumi/documentary episode
+func (v *VideoInfo) IsPGC() bool {
+	return v.EpID != 0
 }
 
 type UrlProcessor struct {
@@ -43,6 +95,14 @@ func GetVideoInfosById(id string) ([]*VideoInfo, error) {
 		return nil, err
 	}
 
+	if isPGCId(id) {
+		if err := p.QuerySeason(); err != nil {
+			log.Errorf("query season error: %v", err)
+			return nil, err
+		}
+		return p.urls, nil
+	}
+
 	if err := p.QueryAidCids(); err != nil {
 		log.Errorf("query aid and cid error: %v", err)
 		return nil, err
@@ -53,19 +113,31 @@ func GetVideoInfosById(id string) ([]*VideoInfo, error) {
 
 // CheckArgs check if video id legal
 func (p *UrlProcessor) CheckArgs() error {
-	if len(p.videoId) < 2 {
-		return fmt.Errorf("id length too short: %v", len(p.videoId))
+	if err := checkId(p.videoId); err != nil {
+		return err
+	}
+
+	log.Infof("check video id %v passed", p.videoId)
+
+	return nil
+}
+
+// checkId check if id is a video or bangumi id
+func checkId(id string) error {
+	if len(id) < 2 {
+		return fmt.Errorf("id length too short: %v", len(id))
 	}
 
-	switch p.videoId[:2] {
+	switch id[:2] {
 	case "av", "BV":
 		// donothing
+	case "ep", "ss", "md":
+		if _, err := strconv.ParseInt(id[2:], 10, 64); err != nil {
+			return fmt.Errorf("invalid bangumi id %v: %v", id, err)
+		}
 	default:
-		return fmt.Errorf("unrecognized video id %v, should starts with av/BV", p.videoId)
+		return fmt.Errorf("unrecognized video id %v, should starts with av/BV/ep/ss/md", id)
 	}
-
-	log.Infof("check video id %v passed", p.videoId)
-
 	return nil
 }
 
@@ -129,19 +201,29 @@ func (p *UrlProcessor) QueryAidCids() error {
 			}
 
 			title := gjson.Get(jsTxt, "videoData.title").String()
+			meta := parseVideoMeta(gjson.Parse(jsTxt))
+			if len(meta.Tags) == 0 {
+				if tags, err := getVideoTags(avid); err != nil {
+					log.Warnf("get tags of %v error: %v", p.videoId, err)
+				} else {
+					meta.Tags = tags
+				}
+			}
 			for _, page := range pages.Array() {
 				cid := page.Get("cid").Int()
 				pageNo := page.Get("page").Int()
 				part := page.Get("part").String()
 				length := page.Get("duration").Int()
 				url := &VideoInfo{
-					VideoID:  p.videoId,
-					Avid:     avid,
-					Cid:      cid,
-					Title:    title,
-					Page:     pageNo,
-					Duration: length,
-					PartName: part,
+					VideoID:    p.videoId,
+					Avid:       avid,
+					Cid:        cid,
+					Title:      title,
+					Page:       pageNo,
+					Duration:   length,
+					PartName:   part,
+					FirstFrame: httpsUrl(page.Get("first_frame").String()),
+					Meta:       meta,
 				}
 				// buf, _ := page.MarshalJSON()
 				// log.Infof("page content is %s", buf)
@@ -16,3 +16,28 @@ func TestGetVideoInfosById(t *testing.T) {
 	require.Nil(t, err)
 	fmt.Printf("%v\n", utils.Json(urls))
 }
+
+func TestParseId(t *testing.T) {
+	for in, id := range map[string]string{
+		"BV1pP4y1b7iP": "BV1pP4y1b7iP",
+		" ep123 ":      "ep123",
+		"https://www.bilibili.com/video/BV1pP4y1b7iP?p=2&share_source=copy": "BV1pP4y1b7iP",
+		"www.bilibili.com/video/av891245009/":                               "av891245009",
+		"https://m.bilibili.com/bangumi/play/ep123":                         "ep123",
+		"https://www.bilibili.com/bangumi/media/md28229051":                 "md28229051",
+	} {
+		got, err := ParseId(in)
+		require.Nil(t, err, in)
+		require.Equal(t, id, got, in)
+	}
+	for _, in := range []string{
+		"https://b23.tv/abcdef",
+		"https://www.bilibili.com/read/cv123",
+		"https://evil.com/video/BV1pP4y1b7iP",
+		"xx",
+		"https://www.bilibili.com/bangumi/play/epxx",
+	} {
+		_, err := ParseId(in)
+		require.NotNil(t, err, in)
+	}
+}
@@ -0,0 +1,188 @@
+package passport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	kQRGenerateUrl = "https://passport.bilibili.com/x/passport-login/web/qrcode/generate"
+	kQRPollUrl     = "https://passport.bilibili.com/x/passport-login/web/qrcode/poll"
+)
+
+// QRStatus is status of qrcode login
+type QRStatus int64
+
+const (
+	QRConfirmed  QRStatus = 0
+	QRExpired    QRStatus = 86038
+	QRScanned    QRStatus = 86090 // scanned, waiting for confirm on phone
+	QRNotScanned QRStatus = 86101
+)
+
+func (s QRStatus) String() string {
+	switch s {
+	case QRConfirmed:
+		return "confirmed"
+	case QRExpired:
+		return "expired"
+	case QRScanned:
+		return "scanned, waiting for confirm"
+	case QRNotScanned:
+		return "not scanned"
+	default:
+		return fmt.Sprintf("unknown status %d", int64(s))
+	}
+}
+
+// ErrQRExpired is returned when qrcode expired before confirmed
+var ErrQRExpired = errors.New("qrcode expired")
+
+// QRCode is a login qrcode, Url should be encoded as qrcode and scanned by app
+type QRCode struct {
+	Url string
+	Key string
+}
+
+func getJson(ctx context.Context, u string, params url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("user-agent", httpcli.UA)
+	req.Header.Set("referer", "https://www.bilibili.com/")
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := httpcli.Inst.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code %v", resp.StatusCode)
+	}
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if code := gjson.GetBytes(buf, "code"); code.Type == gjson.Null {
+		return nil, errors.New("null json")
+	} else if code.Int() != 0 {
+		return nil, fmt.Errorf("code not 0: %v, message: %v", code.Int(), gjson.GetBytes(buf, "message").String())
+	}
+	return buf, nil
+}
+
+// GenerateQRCode apply a new login qrcode
+func GenerateQRCode(ctx context.Context) (*QRCode, error) {
+	buf, err := getJson(ctx, kQRGenerateUrl, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	qr := &QRCode{
+		Url: gjson.GetBytes(buf, "data.url").String(),
+		Key: gjson.GetBytes(buf, "data.qrcode_key").String(),
+	}
+	if qr.Url == "" || qr.Key == "" {
+		return nil, errors.New("empty qrcode url or key")
+	}
+	return qr, nil
+}
+
+// PollQRCode query status of qrcode, cookies are saved into jar when confirmed
+func PollQRCode(ctx context.Context, key string) (QRStatus, error) {
+	buf, err := getJson(ctx, kQRPollUrl, url.Values{"qrcode_key": {key}})
+	if err != nil {
+		return 0, err
+	}
+	status := QRStatus(gjson.GetBytes(buf, "data.code").Int())
+	if status == QRConfirmed {
+		// cookies are set by response header, they are also carried by
+		// redirect url for cross domain login, save them for safety
+		if u := gjson.GetBytes(buf, "data.url").String(); u != "" {
+			saveUrlCookies(u)
+		}
+	}
+	return status, nil
+}
+
+// saveUrlCookies save cookies carried by query of login redirect url
+func saveUrlCookies(u string) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		log.Warnf("parse login url error: %v", err)
+		return
+	}
+	q := parsed.Query()
+	var expires time.Time
+	if ts, err := strconv.ParseInt(q.Get("Expires"), 10, 64); err == nil && ts > 0 {
+		expires = time.Unix(ts, 0)
+	}
+	var cks []*http.Cookie
+	for _, name := range []string{"DedeUserID", "DedeUserID__ckMd5", "SESSDATA", "bili_jct"} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		cks = append(cks, &http.Cookie{
+			Name:     name,
+			Value:    v,
+			Path:     "/",
+			Domain:   ".bilibili.com",
+			Expires:  expires,
+			Secure:   true,
+			HttpOnly: strings.EqualFold(name, "SESSDATA"),
+		})
+	}
+	cookie.SetCookies(cks)
+}
+
+// LoginByQRCode run the whole qrcode login flow, show is called with qrcode url to
+// display, onStatus is called when status changed, it returns when login confirmed
+func LoginByQRCode(ctx context.Context, show func(qrUrl string), onStatus func(QRStatus)) error {
+	qr, err := GenerateQRCode(ctx)
+	if err != nil {
+		return err
+	}
+	show(qr.Url)
+
+	var (
+		last   QRStatus = -1
+		ticker          = time.NewTicker(2 * time.Second)
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		status, err := PollQRCode(ctx, qr.Key)
+		if err != nil {
+			log.Warnf("poll qrcode error: %v", err)
+			continue
+		}
+		if status != last && onStatus != nil {
+			onStatus(status)
+		}
+		last = status
+		switch status {
+		case QRConfirmed:
+			return nil
+		case QRExpired:
+			return ErrQRExpired
+		}
+	}
+}
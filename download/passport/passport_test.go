@@ -0,0 +1,27 @@
+package passport
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveUrlCookies(t *testing.T) {
+	saveUrlCookies("https://passport.biligame.com/x/passport-login/web/crossDomain?DedeUserID=123&DedeUserID__ckMd5=abc&Expires=4102444800&SESSDATA=sess%2C1&bili_jct=jct&gourl=https%3A%2F%2Fwww.bilibili.com")
+
+	u, _ := url.Parse("https://api.bilibili.com/x/web-interface/nav")
+	got := map[string]string{}
+	for _, ck := range cookie.GetCookieJar().Cookies(u) {
+		got[ck.Name] = ck.Value
+	}
+	require.Equal(t, "123", got["DedeUserID"])
+	require.Equal(t, "sess,1", got["SESSDATA"])
+	require.Equal(t, "jct", got["bili_jct"])
+}
+
+func TestQRStatus(t *testing.T) {
+	require.Equal(t, "expired", QRExpired.String())
+	require.Equal(t, "unknown status 1", QRStatus(1).String())
+}
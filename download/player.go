@@ -0,0 +1,114 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	kPlayerUrl = "https://api.bilibili.com/x/player/wbi/v2"
+)
+
+// SubtitleInfo is a closed caption track of video
+type SubtitleInfo struct {
+	ID      int64  `json:"id"`
+	Lan     string `json:"lan"`     // language like zh-CN, ai-zh
+	LanDoc  string `json:"lan_doc"` // language description
+	Url     string `json:"url"`     // bcc json url
+	AIType  int64  `json:"ai_type"` // 0 for uploaded by uploader
+	IsAI    bool   `json:"is_ai"`   // generated by ai
+	Subject string `json:"subject,omitempty"`
+}
+
+// ViewPoint is a chapter of video
+type ViewPoint struct {
+	Type    int64  `json:"type"`
+	From    int64  `json:"from"` // start second
+	To      int64  `json:"to"`   // end second
+	Content string `json:"content"`
+	ImgUrl  string `json:"img_url,omitempty"`
+}
+
+// PlayerInfo is extra info provided by player api
+type PlayerInfo struct {
+	Avid       int64           `json:"avid"`
+	Cid        int64           `json:"cid"`
+	Subtitles  []*SubtitleInfo `json:"subtitles"`
+	ViewPoints []*ViewPoint    `json:"view_points"`
+}
+
+// GetPlayerInfo get player info of video page
+func GetPlayerInfo(avid, cid int64) (*PlayerInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, kPlayerUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	q := req.URL.Query()
+	q.Set("aid", strconv.FormatInt(avid, 10))
+	q.Set("cid", strconv.FormatInt(cid, 10))
+	req.URL.RawQuery = q.Encode()
+
+	buf, err := doApiRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePlayerInfo(avid, cid, gjson.GetBytes(buf, "data")), nil
+}
+
+func parsePlayerInfo(avid, cid int64, data gjson.Result) *PlayerInfo {
+	info := &PlayerInfo{
+		Avid: avid,
+		Cid:  cid,
+	}
+	for _, sub := range data.Get("subtitle.subtitles").Array() {
+		u := httpsUrl(sub.Get("subtitle_url").String())
+		lan := sub.Get("lan").String()
+		info.Subtitles = append(info.Subtitles, &SubtitleInfo{
+			ID:     sub.Get("id").Int(),
+			Lan:    lan,
+			LanDoc: sub.Get("lan_doc").String(),
+			Url:    u,
+			AIType: sub.Get("ai_type").Int(),
+			IsAI:   strings.HasPrefix(lan, "ai-"),
+		})
+	}
+	for _, vp := range data.Get("view_points").Array() {
+		info.ViewPoints = append(info.ViewPoints, &ViewPoint{
+			Type:    vp.Get("type").Int(),
+			From:    vp.Get("from").Int(),
+			To:      vp.Get("to").Int(),
+			Content: vp.Get("content").String(),
+			ImgUrl:  httpsUrl(vp.Get("imgUrl").String()),
+		})
+	}
+	return info
+}
+
+// GetSubtitle get bcc json subtitle content
+func GetSubtitle(sub *SubtitleInfo) ([]byte, error) {
+	if sub.Url == "" {
+		return nil, fmt.Errorf("subtitle %v has no url", sub.Lan)
+	}
+	req, err := http.NewRequest(http.MethodGet, sub.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("user-agent", httpcli.UA)
+	resp, err := httpcli.Inst.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code %v", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
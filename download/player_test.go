@@ -0,0 +1,32 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestParsePlayerInfo(t *testing.T) {
+	const data = `{
+		"subtitle": {
+			"subtitles": [
+				{"id": 1, "lan": "zh-CN", "lan_doc": "中文（中国）", "subtitle_url": "//aisubtitle.hdslb.com/bfs/subtitle/1.json", "ai_type": 0},
+				{"id": 2, "lan": "ai-en", "lan_doc": "English (AI)", "subtitle_url": "https://aisubtitle.hdslb.com/bfs/ai_subtitle/2.json", "ai_type": 1}
+			]
+		},
+		"view_points": [
+			{"type": 2, "from": 0, "to": 30, "content": "开头", "imgUrl": "//i0.hdslb.com/bfs/vchapter/1.jpg"},
+			{"type": 2, "from": 30, "to": 125, "content": "正片"}
+		]
+	}`
+	info := parsePlayerInfo(1, 2, gjson.Parse(data))
+	require.Len(t, info.Subtitles, 2)
+	require.Equal(t, "https://aisubtitle.hdslb.com/bfs/subtitle/1.json", info.Subtitles[0].Url)
+	require.False(t, info.Subtitles[0].IsAI)
+	require.True(t, info.Subtitles[1].IsAI)
+	require.Equal(t, "ai-en", info.Subtitles[1].Lan)
+	require.Len(t, info.ViewPoints, 2)
+	require.Equal(t, &ViewPoint{Type: 2, From: 30, To: 125, Content: "正片"}, info.ViewPoints[1])
+	require.Equal(t, "https://i0.hdslb.com/bfs/vchapter/1.jpg", info.ViewPoints[0].ImgUrl)
+}
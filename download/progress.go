@@ -1,78 +1,41 @@
 package download
 
 import (
-	"os"
-	"strings"
-	"sync"
 	"sync/atomic"
-	"time"
-
-	"github.com/rammiah/bili-downloader/consts"
 )
 
-type ProgressBar struct {
-	Total       int64
-	Done        int64
-	lastPercent int64
-	stopOnce    sync.Once
-	stop        chan struct{}
+// ProgressReporter show progress of downloading files, implementations are in package progress
+type ProgressReporter interface {
+	// Start report a file of total bytes, it's called when download of file begins
+	Start(name string, total int64) ProgressTracker
 }
 
-func NewProgressBar(total int64, wg *sync.WaitGroup) *ProgressBar {
-	p := &ProgressBar{
-		Total: total,
-		stop:  make(chan struct{}, 1),
-	}
-
-	wg.Add(1)
-	go p.print(wg)
-
-	return p
+// ProgressTracker receive progress of a file, methods are called from worker goroutines
+type ProgressTracker interface {
+	// Add n bytes downloaded, n is negative when bytes of failed fragment are taken back
+	Add(n int64)
+	// Finish download of file, err is nil if succeeded
+	Finish(err error)
 }
 
-func (p *ProgressBar) print(wg *sync.WaitGroup) {
-	defer func() {
-		// fmt.Printf("progress bar exited\n")
-		wg.Done()
-	}()
-	ticker := time.NewTicker(time.Millisecond * 30)
-	for p.lastPercent < 100 {
-		select {
-		case <-ticker.C:
-		case <-p.stop:
-			os.Stdout.WriteString("\n")
-			return
-		}
-		done := atomic.LoadInt64(&p.Done)
-		percent := int(100.0 * float64(done) / float64(p.Total))
-		if done >= p.Total {
-			percent = 100
-		}
-		// if percent > int(p.lastPercent) {
-		cmd := strings.Repeat("\b", 200) + consts.Byte(done).String() + "/" + consts.Byte(p.Total).String() +
-			" [" + strings.Repeat("#", percent) + strings.Repeat(" ", 100-percent) + "]"
-		if percent >= 100 {
-			cmd += "\n"
-		}
-		os.Stdout.WriteString(cmd)
-		os.Stdout.Sync()
-		p.lastPercent = int64(percent)
-		// }
-	}
+// progressCounter count bytes downloaded and forward them to tracker
+type progressCounter struct {
+	done    int64
+	tracker ProgressTracker // nil if not reported
 }
 
-func (p *ProgressBar) Stop() {
-	p.stopOnce.Do(func() {
-		close(p.stop)
-	})
+func (p *progressCounter) Add(n int64) {
+	atomic.AddInt64(&p.done, n)
+	if p.tracker != nil {
+		p.tracker.Add(n)
+	}
 }
 
-func (p *ProgressBar) Add(val int64) {
-	val = atomic.AddInt64(&p.Done, val)
+func (p *progressCounter) Done() int64 {
+	return atomic.LoadInt64(&p.done)
 }
 
-func (p *ProgressBar) Write(buf []byte) (int, error) {
+func (p *progressCounter) Write(buf []byte) (int, error) {
 	p.Add(int64(len(buf)))
-
 	return len(buf), nil
 }
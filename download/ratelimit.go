@@ -0,0 +1,104 @@
+package download
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter token bucket limiting bytes per second, shared by downloaders to limit total speed
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter create limiter of bps bytes per second, nil returned if bps <= 0 means no limit
+func NewRateLimiter(bps int64) *RateLimiter {
+	if bps <= 0 {
+		return nil
+	}
+	burst := float64(bps)
+	if burst < 32*1024 {
+		burst = 32 * 1024
+	}
+	return &RateLimiter{
+		rate:   float64(bps),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve take n tokens and return time to wait before using them
+func (l *RateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// WaitN block until n bytes are allowed
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	wait := l.reserve(n)
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Duration return time needed to read n bytes, 0 if l is nil
+func (l *RateLimiter) Duration(n int64) time.Duration {
+	if l == nil {
+		return 0
+	}
+	return time.Duration(float64(n) / l.rate * float64(time.Second))
+}
+
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *RateLimiter
+}
+
+// Read read at most burst bytes once so waiting is smooth
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if max := int(r.l.burst); len(p) > max {
+		p = p[:max]
+	}
+	n, err := r.r.Read(p)
+	if werr := r.l.WaitN(r.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// Reader wrap r so reading from it is limited, r returned if l is nil
+func (l *RateLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, l: l}
+}
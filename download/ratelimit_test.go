@@ -0,0 +1,34 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	require.Nil(t, NewRateLimiter(0))
+	var nop *RateLimiter
+	require.Nil(t, nop.WaitN(context.Background(), 1<<20))
+	require.Zero(t, nop.Duration(1<<20))
+
+	const rate = 1 << 20
+	l := NewRateLimiter(rate)
+	require.Equal(t, 2*time.Second, l.Duration(2*rate))
+
+	// burst of one second is free, the next half second is waited
+	start := time.Now()
+	n, err := io.Copy(io.Discard, l.Reader(context.Background(), bytes.NewReader(make([]byte, rate*3/2))))
+	require.Nil(t, err)
+	require.EqualValues(t, rate*3/2, n)
+	cost := time.Since(start)
+	require.True(t, cost > 400*time.Millisecond && cost < 2*time.Second, cost)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NotNil(t, l.WaitN(ctx, rate))
+}
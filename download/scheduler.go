@@ -0,0 +1,91 @@
+package download
+
+import (
+	"context"
+	"sync"
+)
+
+// ConnPool bound connections of downloaders sharing it, nil pool is unbounded
+type ConnPool struct {
+	tokens chan struct{}
+}
+
+// NewConnPool create pool of n connections, nil if n <= 0
+func NewConnPool(n int) *ConnPool {
+	if n <= 0 {
+		return nil
+	}
+	return &ConnPool{tokens: make(chan struct{}, n)}
+}
+
+// Size return connections of pool, 0 for unbounded
+func (p *ConnPool) Size() int {
+	if p == nil {
+		return 0
+	}
+	return cap(p.tokens)
+}
+
+// acquire take a connection, error of ctx is returned if it's done before
+func (p *ConnPool) acquire(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	select {
+	case p.tokens <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ConnPool) release() {
+	if p != nil {
+		<-p.tokens
+	}
+}
+
+// SetConnPool take a connection of p for every fragment request, p is shared by downloaders
+func (d *VideoDownloader) SetConnPool(p *ConnPool) *VideoDownloader {
+	d.conns = p
+	return d
+}
+
+// Scheduler download several files at the same time, connections of all of them are bounded by a pool.
+// Submit blocks while all files slots are busy, so caller can get playurl of the next file ahead,
+// it's downloaded as soon as a file finishes.
+type Scheduler struct {
+	slots chan struct{}
+	conns *ConnPool
+	wg    sync.WaitGroup
+}
+
+// NewScheduler create scheduler download files at the same time with conns connections in total,
+// files <= 0 is taken as 1, conns <= 0 for no bound
+func NewScheduler(files, conns int) *Scheduler {
+	if files <= 0 {
+		files = 1
+	}
+	return &Scheduler{
+		slots: make(chan struct{}, files),
+		conns: NewConnPool(conns),
+	}
+}
+
+// Submit download d when a file slot is free, done is called with result of download in another goroutine
+func (s *Scheduler) Submit(d *VideoDownloader, done func(err error)) {
+	s.slots <- struct{}{}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := d.SetConnPool(s.conns).Download()
+		// post processing of done doesn't hold the slot
+		<-s.slots
+		done(err)
+	}()
+}
+
+// Wait until all submitted files are done
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,64 @@
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), consts.FragSize/10+1000)
+	var active, maxActive int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			n := atomic.AddInt64(&active, 1)
+			defer atomic.AddInt64(&active, -1)
+			for {
+				m := atomic.LoadInt64(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt64(&maxActive, m, n) {
+					break
+				}
+			}
+			time.Sleep(400 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "a.flv", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	s := NewScheduler(2, 3)
+	var mu sync.Mutex
+	results := map[string]error{}
+	for _, name := range []string{"1.flv", "2.flv", "3.flv"} {
+		of, err := os.Create(filepath.Join(dir, name))
+		require.Nil(t, err)
+		info := &DownloadInfo{VideoID: "BV1pP4y1b7iP", Url: srv.URL, Size: int64(len(content))}
+		s.Submit(NewVideoDownloader(info, of).SetConcurrency(2), func(err error) {
+			of.Close()
+			mu.Lock()
+			results[of.Name()] = err
+			mu.Unlock()
+		})
+	}
+	s.Wait()
+
+	require.Len(t, results, 3)
+	for name, err := range results {
+		require.Nil(t, err)
+		data, err := ioutil.ReadFile(name)
+		require.Nil(t, err)
+		require.Equal(t, content, data)
+	}
+	// 2 files of 2 workers share 3 connections
+	require.EqualValues(t, 3, maxActive)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"runtime"
 	"sync"
@@ -17,6 +18,7 @@ import (
 	"github.com/apex/log"
 	"github.com/rammiah/bili-downloader/consts"
 	"github.com/rammiah/bili-downloader/download/httpcli"
+	"github.com/rammiah/bili-downloader/metrics"
 )
 
 // VideoFragment download video in parallel
@@ -31,13 +33,28 @@ type VideoDownloader struct {
 	out      *os.File
 	frags    []*VideoFragment
 	count    int64
-	errVal   *atomic.Value
+	errVal   *atomic.Value // first error of workers as downloadErr
+	errOnce  sync.Once
 
 	downIdx int64
 	redIdx  int64
-	pg      *ProgressBar
+	pg      *progressCounter
+
+	concurrency int          // workers count, NumCPU if not set
+	limiter     *RateLimiter // nil for no limit
+	retries     int          // retries of a fragment before giving up
+	reporter    ProgressReporter
+	observers   []Observer
+	conns       *ConnPool // shared by downloaders, nil for no bound
+	ctx         context.Context
+	host        string // host of cdn, label of metrics
 }
 
+const (
+	defaultRetries = 3
+	maxRetryDelay  = 8 * time.Second
+)
+
 func buildFrags(info *DownloadInfo) []*VideoFragment {
 	fragCnt := info.Size/consts.FragSize + 1
 	if info.Size%consts.FragSize == 0 {
@@ -59,6 +76,15 @@ func buildFrags(info *DownloadInfo) []*VideoFragment {
 	return frags
 }
 
+// urlHost return host of url, empty if invalid
+func urlHost(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
 func NewVideoDownloader(info *DownloadInfo, out *os.File) *VideoDownloader {
 	syscall.Fallocate(int(out.Fd()), 0, 0, info.Size)
 	d := &VideoDownloader{
@@ -68,15 +94,55 @@ func NewVideoDownloader(info *DownloadInfo, out *os.File) *VideoDownloader {
 		frags:    buildFrags(info),
 		downIdx:  0,
 		errVal:   &atomic.Value{},
+		retries:  defaultRetries,
+		pg:       &progressCounter{},
+		ctx:      context.Background(),
+		host:     urlHost(info.Url),
 	}
-	d.pg = NewProgressBar(info.Size, d.wg)
 	d.count = int64(len(d.frags))
 
 	return d
 }
 
-func (d *VideoDownloader) DownloadFragment(frag *VideoFragment) ([]byte, error) {
+// SetConcurrency set count of fragments downloaded at the same time
+func (d *VideoDownloader) SetConcurrency(n int) *VideoDownloader {
+	d.concurrency = n
+	return d
+}
+
+// SetRetries set times a failed fragment is downloaded again, 0 to fail at the first error
+func (d *VideoDownloader) SetRetries(n int) *VideoDownloader {
+	d.retries = n
+	return d
+}
+
+// SetProgressReporter report progress of download to r, nothing is reported if not set
+func (d *VideoDownloader) SetProgressReporter(r ProgressReporter) *VideoDownloader {
+	d.reporter = r
+	return d
+}
+
+// SetContext stop download when ctx is done, Download returns error of ctx then
+func (d *VideoDownloader) SetContext(ctx context.Context) *VideoDownloader {
+	d.ctx = ctx
+	return d
+}
+
+// SetRateLimiter limit download speed by l, l can be shared by downloaders
+func (d *VideoDownloader) SetRateLimiter(l *RateLimiter) *VideoDownloader {
+	d.limiter = l
+	return d
+}
+
+func (d *VideoDownloader) DownloadFragment(frag *VideoFragment) (data []byte, err error) {
 	info := d.downInfo
+	defer func(start time.Time) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		metrics.FragmentDuration.WithLabelValues(d.host, result).Observe(time.Since(start).Seconds())
+	}(time.Now())
 
 	// auth audio
 	if err := authVideo(info.VideoID, info.Url); err != nil {
@@ -84,7 +150,13 @@ func (d *VideoDownloader) DownloadFragment(frag *VideoFragment) ([]byte, error)
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// fragments of all connections share the limited speed, give them time to finish
+	conns := d.concurrency
+	if n := d.conns.Size(); n > 0 {
+		conns = n
+	}
+	timeout := 10*time.Second + d.limiter.Duration((frag.End-frag.Begin+1)*int64(conns))
+	ctx, cancel := context.WithTimeout(d.ctx, timeout)
 	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.Url, nil)
 	if err != nil {
@@ -97,7 +169,7 @@ func (d *VideoDownloader) DownloadFragment(frag *VideoFragment) ([]byte, error)
 		"accept-language":    "zh-CN,zh;q=0.9,en-US;q=0.8,en;q=0.7",
 		"dnt":                "1",
 		"origin":             "https://www.bilibili.com",
-		"referer":            "https://www.bilibili.com/video/" + info.VideoID,
+		"referer":            videoPageUrl(info.VideoID),
 		"sec-ch-ua":          `"Google Chrome";v="95", "Chromium";v="95", ";Not A Brand";v="99"`,
 		"sec-ch-ua-mobile":   "?0",
 		"sec-ch-ua-platform": "Windows",
@@ -132,9 +204,12 @@ func (d *VideoDownloader) DownloadFragment(frag *VideoFragment) ([]byte, error)
 	buf.Reset()
 
 	wr := io.MultiWriter(buf, d.pg)
-	_, err = io.Copy(wr, resp.Body)
+	_, err = io.Copy(wr, d.limiter.Reader(ctx, resp.Body))
+	metrics.DownloadedBytes.WithLabelValues(d.host).Add(float64(buf.Len()))
 	if err != nil {
 		// log.Errorf("read resp data error: %v", err)
+		// bytes of failed fragment will be downloaded again
+		d.pg.Add(-int64(buf.Len()))
 		return nil, err
 	}
 
@@ -143,19 +218,35 @@ func (d *VideoDownloader) DownloadFragment(frag *VideoFragment) ([]byte, error)
 	return buf.Bytes(), nil
 }
 
+// downloadErr wrap errors of different types, as atomic.Value only stores values of one type
+type downloadErr struct {
+	err error
+}
+
+// storeErr keep the first error, workers stop as they see it
+func (d *VideoDownloader) storeErr(err error) {
+	d.errOnce.Do(func() {
+		d.errVal.Store(downloadErr{err: err})
+	})
+}
+
+func (d *VideoDownloader) loadErr() error {
+	v, _ := d.errVal.Load().(downloadErr)
+	return v.err
+}
+
 func (d *VideoDownloader) startWorker(id int) {
-	defer func() {
-		d.wg.Done()
-		if d.errVal.Load() != nil {
-			d.pg.Stop()
-		}
-	}()
+	defer d.wg.Done()
 	for {
 		// check error
-		if err := d.errVal.Load(); err != nil {
+		if err := d.loadErr(); err != nil {
 			// log.Infof("error detected: %v", err)
 			return
 		}
+		if err := d.ctx.Err(); err != nil {
+			d.storeErr(err)
+			return
+		}
 		idx := atomic.AddInt64(&d.downIdx, 1) - 1
 		if idx >= int64(len(d.frags)) {
 			// log.Infof("worker %v exit", id)
@@ -166,14 +257,18 @@ func (d *VideoDownloader) startWorker(id int) {
 		// random sleep
 		time.Sleep(time.Duration(rand.Intn(100)+200) * time.Millisecond)
 		// log.Infof("download frag %v, %v - %v", idx, frag.Begin, frag.End)
-		data, err := d.DownloadFragment(frag)
+		start := time.Now()
+		for _, o := range d.observers {
+			o.OnFragmentStart(int(idx), frag)
+		}
+		data, err := d.downloadWithRetry(int(idx), frag)
 		if err != nil {
 			// log.Errorf("download %v error: %v", idx, err)
-			d.errVal.Store(err)
+			d.storeErr(err)
 			return
 		}
 
-		if err := d.errVal.Load(); err != nil {
+		if err := d.loadErr(); err != nil {
 			// log.Infof("error detected: %v\n", err)
 			return
 		}
@@ -181,25 +276,97 @@ func (d *VideoDownloader) startWorker(id int) {
 		_, err = d.out.WriteAt(data, frag.Begin)
 		if err != nil {
 			// log.Errorf("write file error: %v", err)
-			d.errVal.Store(err)
+			d.storeErr(err)
 			return
 		}
 
 		// log.Infof("download frag %v success", idx)
+		elapsed := time.Since(start)
+		for _, o := range d.observers {
+			o.OnFragmentDone(int(idx), int64(len(data)), elapsed)
+		}
+	}
+}
+
+// downloadWithRetry download fragment, retry with backoff if failed
+func (d *VideoDownloader) downloadWithRetry(idx int, frag *VideoFragment) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		if err := d.conns.acquire(d.ctx); err != nil {
+			return nil, err
+		}
+		data, err := d.DownloadFragment(frag)
+		d.conns.release()
+		if err == nil {
+			return data, nil
+		}
+		if d.ctx.Err() != nil {
+			return nil, d.ctx.Err()
+		}
+		if attempt > d.retries || d.loadErr() != nil {
+			return nil, err
+		}
+		log.Warnf("download fragment %v error: %v, retry %v/%v", idx, err, attempt, d.retries)
+		metrics.FragmentRetries.WithLabelValues(d.host).Inc()
+		for _, o := range d.observers {
+			o.OnRetry(idx, attempt, err)
+		}
+		delay := time.Second << (attempt - 1)
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-d.ctx.Done():
+			return nil, d.ctx.Err()
+		}
 	}
 }
 
 func (d *VideoDownloader) Download() error {
-	DownThreadCnt := runtime.NumCPU()
+	if d.concurrency <= 0 {
+		d.concurrency = runtime.NumCPU()
+	}
+	DownThreadCnt := d.concurrency
+	start := time.Now()
+	for _, o := range d.observers {
+		o.OnStart(d.downInfo, len(d.frags))
+	}
+	if d.reporter != nil {
+		d.pg.tracker = d.reporter.Start(d.out.Name(), d.downInfo.Size)
+	}
+	stop, exited := make(chan struct{}), make(chan struct{})
+	if len(d.observers) > 0 {
+		go d.progressLoop(stop, exited)
+	} else {
+		close(exited)
+	}
 	for i := 0; i < DownThreadCnt; i++ {
 		d.wg.Add(1)
 		go d.startWorker(i)
 	}
 	d.wg.Wait()
-	if err := d.errVal.Load(); err != nil {
+	close(stop)
+	<-exited
+	d.notifyProgress()
+	if err := d.loadErr(); err != nil {
 		log.Infof("download failed, error: %v", err)
-		return err.(error)
+		for _, o := range d.observers {
+			o.OnError(err)
+		}
+		d.finish(err)
+		return err
 	}
+	d.finish(nil)
 	log.Infof("download success")
+	elapsed := time.Since(start)
+	for _, o := range d.observers {
+		o.OnComplete(elapsed)
+	}
 	return nil
 }
+
+func (d *VideoDownloader) finish(err error) {
+	if d.pg.tracker != nil {
+		d.pg.tracker.Finish(err)
+	}
+}
@@ -0,0 +1,113 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// recordObserver record calls of fragment start and the final result
+type recordObserver struct {
+	NopObserver
+	mu       sync.Mutex
+	started  []int
+	progress int64
+	elapsed  time.Duration
+}
+
+func (o *recordObserver) OnFragmentStart(idx int, frag *VideoFragment) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = append(o.started, idx)
+}
+
+func (o *recordObserver) OnProgress(done, total int64) {
+	atomic.StoreInt64(&o.progress, done)
+}
+
+func (o *recordObserver) OnComplete(elapsed time.Duration) {
+	o.elapsed = elapsed
+}
+
+func TestVideoDownloaderEvents(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), consts.FragSize/10+1000)
+	var gets int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && atomic.AddInt64(&gets, 1) == 1 {
+			// the first fragment request fails
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		http.ServeContent(w, r, "a.flv", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	of, err := os.Create(filepath.Join(t.TempDir(), "a.flv"))
+	require.Nil(t, err)
+	defer of.Close()
+
+	var mu sync.Mutex
+	var events []*Event
+	rec := &recordObserver{}
+	info := &DownloadInfo{VideoID: "BV1pP4y1b7iP", Url: srv.URL, Size: int64(len(content))}
+	err = NewVideoDownloader(info, of).
+		SetConcurrency(1).
+		AddObserver(rec).
+		SetEventHandler(func(e *Event) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		}).
+		Download()
+	require.Nil(t, err)
+
+	data, err := ioutil.ReadFile(of.Name())
+	require.Nil(t, err)
+	require.Equal(t, content, data)
+
+	var types []EventType
+	for _, e := range events {
+		if e.Type != EventProgress {
+			types = append(types, e.Type)
+		}
+	}
+	require.Equal(t, []EventType{EventStart, EventRetry, EventFragmentDone, EventFragmentDone, EventComplete}, types)
+	require.Equal(t, 2, events[0].Fragments)
+	require.Equal(t, 1, events[1].Attempt)
+	require.Contains(t, events[1].Err.Error(), "502")
+	last := events[len(events)-1]
+	require.Equal(t, info.Size, last.Done)
+	require.Equal(t, info.Size, last.Total)
+
+	// fragment retried is started once
+	require.Equal(t, []int{0, 1}, rec.started)
+	require.Equal(t, info.Size, rec.progress)
+	require.True(t, rec.elapsed > time.Second)
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	require.Equal(t, float64(info.Size), testutil.ToFloat64(metrics.DownloadedBytes.WithLabelValues(host)))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.FragmentRetries.WithLabelValues(host)))
+}
+
+func TestVideoDownloaderErr(t *testing.T) {
+	d := &VideoDownloader{errVal: &atomic.Value{}}
+	require.Nil(t, d.loadErr())
+	// errors of different types come from context, http and file writing
+	d.storeErr(context.Canceled)
+	d.storeErr(&os.PathError{Op: "write", Path: "a.flv", Err: os.ErrClosed})
+	require.Equal(t, context.Canceled, d.loadErr())
+}
@@ -0,0 +1,147 @@
+package download
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	wbiKeyTTL = time.Hour // keys are rotated daily, refresh hourly to be safe
+)
+
+// codes of web apis when wbi signature missing or invalid
+const (
+	codeWbiForbidden = -403
+	codeWbiRisk      = -352
+)
+
+// mixinKeyEncTab shuffle table of img_key+sub_key
+var mixinKeyEncTab = [...]int{
+	46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35,
+	27, 43, 5, 49, 33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13,
+	37, 48, 7, 16, 24, 55, 40, 61, 26, 17, 0, 1, 60, 51, 30, 4,
+	22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11, 36, 20, 34, 44, 52,
+}
+
+type wbiKeyCache struct {
+	mu        sync.Mutex
+	imgKey    string
+	subKey    string
+	fetchedAt time.Time
+}
+
+var wbiKeys = &wbiKeyCache{}
+
+// set update keys from wbi_img of nav data
+func (c *wbiKeyCache) set(data gjson.Result) {
+	img := wbiKeyOf(data.Get("wbi_img.img_url").String())
+	sub := wbiKeyOf(data.Get("wbi_img.sub_url").String())
+	if img == "" || sub == "" {
+		return
+	}
+	c.mu.Lock()
+	c.imgKey, c.subKey, c.fetchedAt = img, sub, time.Now()
+	c.mu.Unlock()
+}
+
+// get return cached keys, fetch from nav if expired
+func (c *wbiKeyCache) get() (string, string, error) {
+	c.mu.Lock()
+	img, sub, at := c.imgKey, c.subKey, c.fetchedAt
+	c.mu.Unlock()
+	if img != "" && time.Since(at) < wbiKeyTTL {
+		return img, sub, nil
+	}
+
+	// nav fills the cache
+	if _, err := getNav(); err != nil {
+		return "", "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.imgKey, c.subKey, nil
+}
+
+// expire force keys fetched again on next get
+func (c *wbiKeyCache) expire() {
+	c.mu.Lock()
+	c.fetchedAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// wbiKeyOf get key from url like https://i0.hdslb.com/bfs/wbi/<key>.png
+func wbiKeyOf(u string) string {
+	name := path.Base(u)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// mixinKey shuffle img and sub key to the key used for signing
+func mixinKey(imgKey, subKey string) string {
+	raw := imgKey + subKey
+	var sb strings.Builder
+	for _, i := range mixinKeyEncTab {
+		if i < len(raw) {
+			sb.WriteByte(raw[i])
+		}
+	}
+	key := sb.String()
+	if len(key) > 32 {
+		key = key[:32]
+	}
+	return key
+}
+
+// signWbi add wts and w_rid to query, return encoded query
+func signWbi(q url.Values, imgKey, subKey string, now time.Time) string {
+	signed := url.Values{}
+	for k, vs := range q {
+		if k == "w_rid" || k == "wts" {
+			continue
+		}
+		for _, v := range vs {
+			signed.Add(k, strings.Map(func(r rune) rune {
+				if strings.ContainsRune("!'()*", r) {
+					return -1
+				}
+				return r
+			}, v))
+		}
+	}
+	signed.Set("wts", strconv.FormatInt(now.Unix(), 10))
+	// same as encodeURIComponent used by web page
+	query := strings.ReplaceAll(signed.Encode(), "+", "%20")
+	sum := md5.Sum([]byte(query + mixinKey(imgKey, subKey)))
+	return query + "&w_rid=" + hex.EncodeToString(sum[:])
+}
+
+// needWbi report if request is to a web api checking wbi signature
+func needWbi(req *http.Request) bool {
+	return req.URL.Host == "api.bilibili.com" && strings.HasPrefix(req.URL.Path, "/x/")
+}
+
+// signRequest sign query of request in place
+func signRequest(req *http.Request) error {
+	img, sub, err := wbiKeys.get()
+	if err != nil {
+		return err
+	}
+	if img == "" {
+		log.Warnf("wbi keys not found in nav, request %v unsigned", req.URL.Path)
+		return nil
+	}
+	req.URL.RawQuery = signWbi(req.URL.Query(), img, sub, time.Now())
+	return nil
+}
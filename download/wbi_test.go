@@ -0,0 +1,57 @@
+package download
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	testImgKey = "7cd084941338484aae1ad9425b84077c"
+	testSubKey = "4932caff0ff746eab6f01bf08b70ac45"
+)
+
+func TestMixinKey(t *testing.T) {
+	require.Equal(t, "ea1db124af3c7062474693fa704f4ff8", mixinKey(testImgKey, testSubKey))
+}
+
+func TestSignWbi(t *testing.T) {
+	now := time.Unix(1702204169, 0)
+	q := url.Values{"foo": {"114"}, "bar": {"514"}, "zab": {"1919810"}}
+	require.Equal(t, "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+		signWbi(q, testImgKey, testSubKey, now))
+
+	// special characters dropped, space encoded as %20, old signature replaced
+	q = url.Values{"name": {"(x)!"}, "a": {"b c"}, "w_rid": {"old"}, "wts": {"1"}}
+	require.Equal(t, "a=b%20c&name=x&wts=1702204169&w_rid=e098f8435e64a793272ccb6b273ed8e0",
+		signWbi(q, testImgKey, testSubKey, now))
+}
+
+func TestWbiKeys(t *testing.T) {
+	require.Equal(t, testImgKey, wbiKeyOf("https://i0.hdslb.com/bfs/wbi/"+testImgKey+".png"))
+	require.Equal(t, "", wbiKeyOf(""))
+
+	c := &wbiKeyCache{}
+	c.set(gjson.Parse(`{"wbi_img": {
+		"img_url": "https://i0.hdslb.com/bfs/wbi/` + testImgKey + `.png",
+		"sub_url": "https://i0.hdslb.com/bfs/wbi/` + testSubKey + `.png"
+	}}`))
+	img, sub, err := c.get()
+	require.Nil(t, err)
+	require.Equal(t, testImgKey, img)
+	require.Equal(t, testSubKey, sub)
+
+	for u, need := range map[string]bool{
+		kUrlUrl:        true,
+		kPlayerUrl:     true,
+		kPGCPlayUrlUrl: false,
+		kVideoUrl:      false,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		require.Equal(t, need, needWbi(req), u)
+	}
+}
@@ -3,10 +3,14 @@ module github.com/rammiah/bili-downloader
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.0
 	github.com/PuerkitoBio/goquery v1.8.0
 	github.com/apex/log v1.9.0
 	github.com/json-iterator/go v1.1.12
+	github.com/prometheus/client_golang v1.12.2
 	github.com/stretchr/testify v1.7.0
 	github.com/tidwall/gjson v1.12.0
 	golang.org/x/net v0.0.0-20211123203042-d83791d6bcd9
+	gopkg.in/yaml.v3 v3.0.1
+	rsc.io/qr v0.2.0
 )
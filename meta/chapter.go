@@ -0,0 +1,73 @@
+package meta
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rammiah/bili-downloader/download"
+)
+
+// Chapter is a chapter of video in milliseconds
+type Chapter struct {
+	Start int64
+	End   int64
+	Title string
+}
+
+// Chapters convert view points to chapters, missing end is filled by
+// start of next chapter or duration of video
+func Chapters(video *download.VideoInfo, points []*download.ViewPoint) []*Chapter {
+	chapters := make([]*Chapter, 0, len(points))
+	for i, vp := range points {
+		end := vp.To
+		if end <= vp.From {
+			if i+1 < len(points) {
+				end = points[i+1].From
+			} else {
+				end = video.Duration
+			}
+		}
+		if end <= vp.From {
+			continue
+		}
+		chapters = append(chapters, &Chapter{
+			Start: vp.From * 1000,
+			End:   end * 1000,
+			Title: vp.Content,
+		})
+	}
+	return chapters
+}
+
+// FFMetadata render chapters as ffmpeg metadata file, can be muxed into mp4 chapter atoms
+func FFMetadata(video *download.VideoInfo, chapters []*Chapter) string {
+	sb := &strings.Builder{}
+	sb.WriteString(";FFMETADATA1\n")
+	fmt.Fprintf(sb, "title=%s\n", escapeFFMeta(PageTitle(video)))
+	for _, c := range chapters {
+		sb.WriteString("\n[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(sb, "START=%d\nEND=%d\ntitle=%s\n", c.Start, c.End, escapeFFMeta(c.Title))
+	}
+	return sb.String()
+}
+
+// ChapterText render chapters as plain text like "00:01:30 title", one chapter per line
+func ChapterText(chapters []*Chapter) string {
+	sb := &strings.Builder{}
+	for _, c := range chapters {
+		sec := c.Start / 1000
+		fmt.Fprintf(sb, "%02d:%02d:%02d %s\n", sec/3600, sec/60%60, sec%60,
+			strings.ReplaceAll(c.Title, "\n", " "))
+	}
+	return sb.String()
+}
+
+func escapeFFMeta(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"=", `\=`,
+		";", `\;`,
+		"#", `\#`,
+		"\n", "\\\n",
+	).Replace(s)
+}
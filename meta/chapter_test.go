@@ -0,0 +1,46 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChapters(t *testing.T) {
+	video := &download.VideoInfo{VideoID: "BV1xx", Title: "a=b", Duration: 3700}
+	chapters := Chapters(video, []*download.ViewPoint{
+		{From: 0, To: 30, Content: "开头"},
+		{From: 30, To: 0, Content: "正片; #1"},
+		{From: 3600, Content: "结尾"},
+	})
+	require.Equal(t, []*Chapter{
+		{Start: 0, End: 30000, Title: "开头"},
+		{Start: 30000, End: 3600000, Title: "正片; #1"},
+		{Start: 3600000, End: 3700000, Title: "结尾"},
+	}, chapters)
+
+	require.Equal(t, `;FFMETADATA1
+title=a\=b
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=30000
+title=开头
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=30000
+END=3600000
+title=正片\; \#1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=3600000
+END=3700000
+title=结尾
+`, FFMetadata(video, chapters))
+
+	require.Equal(t, "00:00:00 开头\n00:00:30 正片; #1\n01:00:00 结尾\n", ChapterText(chapters))
+}
@@ -0,0 +1,238 @@
+package meta
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"gopkg.in/yaml.v3"
+)
+
+// ListingVersion is bumped when fields of listing are removed or changed, adding fields keeps it
+const ListingVersion = 1
+
+// Listing is output of `bilidown info`, json and yaml share the same field names
+type Listing struct {
+	Version int             `json:"version" yaml:"version"`
+	Videos  []*ListingVideo `json:"videos" yaml:"videos"`
+}
+
+// ListingVideo is a video or bangumi season queried by id
+type ListingVideo struct {
+	ID          string         `json:"id" yaml:"id"` // id given by user
+	Title       string         `json:"title" yaml:"title"`
+	Bvid        string         `json:"bvid,omitempty" yaml:"bvid,omitempty"`
+	Avid        int64          `json:"avid,omitempty" yaml:"avid,omitempty"`
+	SeasonID    int64          `json:"season_id,omitempty" yaml:"season_id,omitempty"`
+	Uploader    string         `json:"uploader,omitempty" yaml:"uploader,omitempty"`
+	Mid         int64          `json:"mid,omitempty" yaml:"mid,omitempty"`
+	Pubdate     string         `json:"pubdate,omitempty" yaml:"pubdate,omitempty"` // RFC 3339 in +08:00
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string       `json:"tags" yaml:"tags"`
+	Cover       string         `json:"cover,omitempty" yaml:"cover,omitempty"`
+	Category    string         `json:"category,omitempty" yaml:"category,omitempty"`
+	Stat        *ListingStat   `json:"stat,omitempty" yaml:"stat,omitempty"`
+	Pages       []*ListingPage `json:"pages" yaml:"pages"`
+}
+
+type ListingStat struct {
+	View     int64 `json:"view" yaml:"view"`
+	Danmaku  int64 `json:"danmaku" yaml:"danmaku"`
+	Reply    int64 `json:"reply" yaml:"reply"`
+	Favorite int64 `json:"favorite" yaml:"favorite"`
+	Coin     int64 `json:"coin" yaml:"coin"`
+	Share    int64 `json:"share" yaml:"share"`
+	Like     int64 `json:"like" yaml:"like"`
+}
+
+// ListingPage is a page of video or an episode of bangumi
+type ListingPage struct {
+	Page       int64            `json:"page" yaml:"page"`         // page no, episode no for bangumi
+	VideoID    string           `json:"video_id" yaml:"video_id"` // BV/av id, ep id for bangumi
+	Avid       int64            `json:"avid" yaml:"avid"`
+	Cid        int64            `json:"cid" yaml:"cid"`
+	EpID       int64            `json:"ep_id,omitempty" yaml:"ep_id,omitempty"`
+	Part       string           `json:"part" yaml:"part"`
+	Duration   int64            `json:"duration" yaml:"duration"` // seconds
+	FirstFrame string           `json:"first_frame,omitempty" yaml:"first_frame,omitempty"`
+	WebUrl     string           `json:"web_url" yaml:"web_url"`
+	Streams    []*ListingStream `json:"streams,omitempty" yaml:"streams,omitempty"`
+	Error      string           `json:"error,omitempty" yaml:"error,omitempty"` // why streams are missing
+}
+
+// ListingStream is a quality of page
+type ListingStream struct {
+	Qn      int64  `json:"qn" yaml:"qn"`
+	Quality string `json:"quality" yaml:"quality"`
+	Need    string `json:"need,omitempty" yaml:"need,omitempty"` // login or vip
+	// selected is the stream current account gets when asking for best quality,
+	// format and size are only known for it
+	Selected bool   `json:"selected" yaml:"selected"`
+	Format   string `json:"format,omitempty" yaml:"format,omitempty"`
+	Size     int64  `json:"size,omitempty" yaml:"size,omitempty"`
+}
+
+// NeedOf return what is needed to get quality qn, login, vip or empty
+func NeedOf(qn int64) string {
+	switch {
+	case consts.NeedVIP(qn):
+		return "vip"
+	case consts.NeedLogin(qn):
+		return "login"
+	}
+	return ""
+}
+
+// ListVideo build listing of videos queried by id, infos are playurl results of videos and nil
+// if not queried, errs are errors of playurl
+func ListVideo(id string, videos []*download.VideoInfo, infos []*download.DownloadInfo, errs []error) *ListingVideo {
+	lv := &ListingVideo{
+		ID:    id,
+		Tags:  []string{},
+		Pages: make([]*ListingPage, 0, len(videos)),
+	}
+	if len(videos) > 0 {
+		lv.Title = videos[0].Title
+		if m := videos[0].Meta; m != nil {
+			lv.Bvid = m.Bvid
+			lv.Avid = m.Avid
+			lv.SeasonID = m.SeasonID
+			lv.Uploader = m.Uploader
+			lv.Mid = m.Mid
+			if m.Pubdate > 0 {
+				lv.Pubdate = m.PubTime().In(consts.CST).Format(time.RFC3339)
+			}
+			lv.Description = m.Description
+			if m.Tags != nil {
+				lv.Tags = m.Tags
+			}
+			lv.Cover = m.Cover
+			lv.Category = m.Tname
+			lv.Stat = &ListingStat{
+				View:     m.Stat.View,
+				Danmaku:  m.Stat.Danmaku,
+				Reply:    m.Stat.Reply,
+				Favorite: m.Stat.Favorite,
+				Coin:     m.Stat.Coin,
+				Share:    m.Stat.Share,
+				Like:     m.Stat.Like,
+			}
+		}
+	}
+
+	for i, video := range videos {
+		page := &ListingPage{
+			Page:       video.Page,
+			VideoID:    video.VideoID,
+			Avid:       video.Avid,
+			Cid:        video.Cid,
+			EpID:       video.EpID,
+			Part:       video.PartName,
+			Duration:   video.Duration,
+			FirstFrame: video.FirstFrame,
+			WebUrl:     WebUrl(video),
+		}
+		if i < len(errs) && errs[i] != nil {
+			page.Error = errs[i].Error()
+		}
+		if i < len(infos) && infos[i] != nil {
+			page.Streams = ListStreams(infos[i])
+		}
+		lv.Pages = append(lv.Pages, page)
+	}
+	return lv
+}
+
+// ListStreams list qualities of playurl result, best first
+func ListStreams(info *download.DownloadInfo) []*ListingStream {
+	accept := info.AcceptQuality
+	if len(accept) == 0 {
+		accept = []int64{info.Qn}
+	}
+	streams := make([]*ListingStream, 0, len(accept))
+	for _, qn := range accept {
+		s := &ListingStream{
+			Qn:      qn,
+			Quality: consts.QualityName(qn),
+			Need:    NeedOf(qn),
+		}
+		if qn == info.Qn {
+			s.Selected = true
+			s.Format = info.Format
+			s.Size = info.Size
+		}
+		streams = append(streams, s)
+	}
+	return streams
+}
+
+// WriteJSON write listing as indented json
+func (l *Listing) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(l)
+}
+
+// WriteYAML write listing as yaml
+func (l *Listing) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(l); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteTable write listing as human readable tables, one table for a video
+func (l *Listing) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, v := range l.Videos {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%v\t%v\n", v.ID, v.Title)
+		if v.Uploader != "" {
+			fmt.Fprintf(tw, "uploader\t%v (%v)\n", v.Uploader, v.Mid)
+		}
+		if v.Pubdate != "" {
+			fmt.Fprintf(tw, "pubdate\t%v\n", v.Pubdate)
+		}
+		if len(v.Tags) > 0 {
+			fmt.Fprintf(tw, "tags\t%v\n", strings.Join(v.Tags, ", "))
+		}
+		if v.Stat != nil {
+			fmt.Fprintf(tw, "stat\t%v views, %v likes, %v coins, %v favorites\n",
+				v.Stat.View, v.Stat.Like, v.Stat.Coin, v.Stat.Favorite)
+		}
+		fmt.Fprintf(tw, "\nPAGE\tID\tDURATION\tPART\tQUALITIES\n")
+		for _, p := range v.Pages {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", p.Page, p.VideoID,
+				time.Duration(p.Duration)*time.Second, p.Part, streamsText(p))
+		}
+	}
+	return tw.Flush()
+}
+
+// streamsText render qualities like 4K(vip) *1080P 720P, * marks the selected one
+func streamsText(p *ListingPage) string {
+	if p.Error != "" {
+		return "error: " + p.Error
+	}
+	parts := make([]string, 0, len(p.Streams))
+	for _, s := range p.Streams {
+		text := s.Quality
+		if s.Need == "vip" {
+			text += "(vip)"
+		}
+		if s.Selected {
+			text = "*" + text
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
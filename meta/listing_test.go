@@ -0,0 +1,77 @@
+package meta
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func testListing() *Listing {
+	p1, p2 := testVideo(), testVideo()
+	p1.Page, p1.PartName = 1, "第一集"
+	infos := []*download.DownloadInfo{
+		{Qn: 80, Format: "flv", Size: 1024, AcceptQuality: []int64{116, 80, 32}},
+		nil,
+	}
+	return &Listing{
+		Version: ListingVersion,
+		Videos: []*ListingVideo{
+			ListVideo("BV1pP4y1b7iP", []*download.VideoInfo{p1, p2}, infos, []error{nil, errors.New("code not 0: -404")}),
+		},
+	}
+}
+
+func TestListVideo(t *testing.T) {
+	v := testListing().Videos[0]
+	require.Equal(t, "2021-11-08T00:00:00+08:00", v.Pubdate)
+	require.Equal(t, "日常", v.Category)
+	require.Len(t, v.Pages, 2)
+	require.Equal(t, "https://www.bilibili.com/video/BV1pP4y1b7iP?p=2", v.Pages[1].WebUrl)
+	require.Equal(t, "code not 0: -404", v.Pages[1].Error)
+	require.Nil(t, v.Pages[1].Streams)
+	require.Equal(t, []*ListingStream{
+		{Qn: 116, Quality: "1080P60", Need: "vip"},
+		{Qn: 80, Quality: "1080P", Need: "login", Selected: true, Format: "flv", Size: 1024},
+		{Qn: 32, Quality: "480P"},
+	}, v.Pages[0].Streams)
+
+	// no metadata
+	v = ListVideo("ep1", []*download.VideoInfo{{VideoID: "ep1", Title: "t", EpID: 1}}, nil, nil)
+	require.Equal(t, []string{}, v.Tags)
+	require.Nil(t, v.Stat)
+	require.Equal(t, "https://www.bilibili.com/bangumi/play/ep1", v.Pages[0].WebUrl)
+}
+
+func TestListingFormats(t *testing.T) {
+	l := testListing()
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, l.WriteJSON(buf))
+	out := buf.String()
+	require.Contains(t, out, `"version": 1,`)
+	require.Contains(t, out, `"desc <b>"`)
+	require.Contains(t, out, `"selected": true,`)
+	var fromJSON Listing
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &fromJSON))
+	require.Equal(t, l, &fromJSON)
+
+	// yaml has the same field names
+	buf.Reset()
+	require.Nil(t, l.WriteYAML(buf))
+	require.Contains(t, buf.String(), "    - page: 1\n")
+	var fromYAML Listing
+	require.Nil(t, yaml.Unmarshal(buf.Bytes(), &fromYAML))
+	require.Equal(t, l, &fromYAML)
+
+	buf.Reset()
+	require.Nil(t, l.WriteTable(buf))
+	out = buf.String()
+	require.Contains(t, out, "BV1pP4y1b7iP  测试视频")
+	require.Contains(t, out, "1080P60(vip) *1080P 480P")
+	require.Contains(t, out, "error: code not 0: -404")
+	require.Contains(t, out, "2m5s")
+}
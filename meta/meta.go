@@ -0,0 +1,152 @@
+package meta
+
+import (
+	"encoding/xml"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+)
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// Info is content of .info.json sidecar
+type Info struct {
+	*download.VideoInfo
+	Download *download.DownloadInfo `json:"download,omitempty"`
+	WebUrl   string                 `json:"web_url"`
+}
+
+// InfoJSON render .info.json sidecar of a downloaded page
+func InfoJSON(video *download.VideoInfo, info *download.DownloadInfo) ([]byte, error) {
+	return json.MarshalIndent(&Info{
+		VideoInfo: video,
+		Download:  info,
+		WebUrl:    WebUrl(video),
+	}, "", "  ")
+}
+
+// WebUrl return web page of video page
+func WebUrl(video *download.VideoInfo) string {
+	if video.IsPGC() {
+		return "https://www.bilibili.com/bangumi/play/ep" + strconv.FormatInt(video.EpID, 10)
+	}
+	u := "https://www.bilibili.com/video/" + video.VideoID
+	if video.Page > 1 {
+		u += "?p=" + strconv.FormatInt(video.Page, 10)
+	}
+	return u
+}
+
+type nfoUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+type nfoThumb struct {
+	Aspect string `xml:"aspect,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+// nfo is kodi style nfo, movie for videos and episodedetails for bangumi
+type nfo struct {
+	XMLName       xml.Name
+	Title         string        `xml:"title"`
+	OriginalTitle string        `xml:"originaltitle,omitempty"`
+	ShowTitle     string        `xml:"showtitle,omitempty"`
+	Episode       int64         `xml:"episode,omitempty"`
+	Plot          string        `xml:"plot,omitempty"`
+	Runtime       int64         `xml:"runtime,omitempty"` // minutes
+	Premiered     string        `xml:"premiered,omitempty"`
+	Year          int           `xml:"year,omitempty"`
+	Studio        string        `xml:"studio,omitempty"`
+	Director      string        `xml:"director,omitempty"`
+	Genre         string        `xml:"genre,omitempty"`
+	Tags          []string      `xml:"tag"`
+	Thumb         *nfoThumb     `xml:"thumb,omitempty"`
+	UniqueIDs     []nfoUniqueID `xml:"uniqueid"`
+	Url           string        `xml:"url,omitempty"`
+}
+
+// NFO render nfo file for media servers like kodi/jellyfin/emby
+func NFO(video *download.VideoInfo) ([]byte, error) {
+	n := &nfo{
+		XMLName:       xml.Name{Local: "movie"},
+		Title:         PageTitle(video),
+		OriginalTitle: video.Title,
+		Runtime:       (video.Duration + 59) / 60,
+		Url:           WebUrl(video),
+		UniqueIDs: []nfoUniqueID{{
+			Type:    "bilibili",
+			Default: true,
+			Value:   video.VideoID,
+		}},
+	}
+	if video.IsPGC() {
+		n.XMLName.Local = "episodedetails"
+		n.ShowTitle = video.Title
+		n.Episode = video.Page
+	}
+	if m := video.Meta; m != nil {
+		n.Plot = m.Description
+		n.Studio = m.Uploader
+		n.Director = m.Uploader
+		n.Genre = m.Tname
+		n.Tags = m.Tags
+		if m.Cover != "" {
+			n.Thumb = &nfoThumb{Aspect: "poster", Value: m.Cover}
+		}
+		if m.Pubdate > 0 {
+			pub := m.PubTime().In(consts.CST)
+			n.Premiered = pub.Format("2006-01-02")
+			n.Year = pub.Year()
+		}
+		if m.Bvid != "" && m.Bvid != video.VideoID {
+			n.UniqueIDs = append(n.UniqueIDs, nfoUniqueID{Type: "bvid", Value: m.Bvid})
+		}
+	}
+
+	buf, err := xml.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header[:len(xml.Header)-1]+"\n"), append(buf, '\n')...), nil
+}
+
+// PageTitle return title of page, part name is appended for multi-page videos
+func PageTitle(video *download.VideoInfo) string {
+	if video.PartName == "" || video.PartName == video.Title {
+		return video.Title
+	}
+	return video.Title + " - " + video.PartName
+}
+
+// MP4Tags return metadata tags to embed into mp4 file
+func MP4Tags(video *download.VideoInfo) map[string]string {
+	tags := map[string]string{
+		"title":   PageTitle(video),
+		"album":   video.Title,
+		"track":   strconv.FormatInt(video.Page, 10),
+		"comment": WebUrl(video),
+	}
+	if m := video.Meta; m != nil {
+		tags["artist"] = m.Uploader
+		tags["album_artist"] = m.Uploader
+		tags["description"] = m.Description
+		tags["synopsis"] = m.Description
+		tags["genre"] = m.Tname
+		if m.Pubdate > 0 {
+			tags["date"] = m.PubTime().In(consts.CST).Format("2006-01-02")
+		}
+	}
+	for k, v := range tags {
+		if v == "" {
+			delete(tags, k)
+		}
+	}
+	return tags
+}
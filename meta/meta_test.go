@@ -0,0 +1,83 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func testVideo() *download.VideoInfo {
+	return &download.VideoInfo{
+		VideoID:  "BV1pP4y1b7iP",
+		Avid:     891245009,
+		Cid:      428280666,
+		Title:    "测试视频",
+		Page:     2,
+		Duration: 125,
+		PartName: "第二集",
+		Meta: &download.VideoMeta{
+			Bvid:        "BV1pP4y1b7iP",
+			Avid:        891245009,
+			Title:       "测试视频",
+			Uploader:    "uploader",
+			Mid:         1234,
+			Pubdate:     1636300800, // 2021-11-08 00:00:00 +0800
+			Description: "desc <b>",
+			Tags:        []string{"tag1", "tag2"},
+			Cover:       "https://i0.hdslb.com/bfs/archive/cover.jpg",
+			Tname:       "日常",
+		},
+	}
+}
+
+func TestNFO(t *testing.T) {
+	buf, err := NFO(testVideo())
+	require.Nil(t, err)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<movie>
+  <title>测试视频 - 第二集</title>
+  <originaltitle>测试视频</originaltitle>
+  <plot>desc &lt;b&gt;</plot>
+  <runtime>3</runtime>
+  <premiered>2021-11-08</premiered>
+  <year>2021</year>
+  <studio>uploader</studio>
+  <director>uploader</director>
+  <genre>日常</genre>
+  <tag>tag1</tag>
+  <tag>tag2</tag>
+  <thumb aspect="poster">https://i0.hdslb.com/bfs/archive/cover.jpg</thumb>
+  <uniqueid type="bilibili" default="true">BV1pP4y1b7iP</uniqueid>
+  <url>https://www.bilibili.com/video/BV1pP4y1b7iP?p=2</url>
+</movie>
+`, string(buf))
+
+	ep := &download.VideoInfo{VideoID: "ep1001", EpID: 1001, Title: "番剧", Page: 3, PartName: "3 标题"}
+	buf, err = NFO(ep)
+	require.Nil(t, err)
+	require.Contains(t, string(buf), "<episodedetails>")
+	require.Contains(t, string(buf), "<episode>3</episode>")
+}
+
+func TestInfoJSON(t *testing.T) {
+	buf, err := InfoJSON(testVideo(), &download.DownloadInfo{Qn: 80, Format: "flv", Size: 100})
+	require.Nil(t, err)
+	require.Equal(t, "BV1pP4y1b7iP", gjson.GetBytes(buf, "video_id").String())
+	require.Equal(t, "uploader", gjson.GetBytes(buf, "meta.uploader").String())
+	require.EqualValues(t, 80, gjson.GetBytes(buf, "download.qn").Int())
+	require.Equal(t, "https://www.bilibili.com/video/BV1pP4y1b7iP?p=2", gjson.GetBytes(buf, "web_url").String())
+}
+
+func TestMP4Tags(t *testing.T) {
+	tags := MP4Tags(testVideo())
+	require.Equal(t, "测试视频 - 第二集", tags["title"])
+	require.Equal(t, "uploader", tags["artist"])
+	require.Equal(t, "2021-11-08", tags["date"])
+	require.Equal(t, "desc <b>", tags["description"])
+
+	tags = MP4Tags(&download.VideoInfo{VideoID: "av1", Title: "t", Page: 1})
+	_, ok := tags["artist"]
+	require.False(t, ok)
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bilidown"
+
+var (
+	// Registry holds metrics of downloader and runtime, served by Handler
+	Registry = prometheus.NewRegistry()
+
+	// DownloadedBytes is bytes received from cdn by host, bytes of failed fragments are counted too
+	DownloadedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes received from cdn.",
+	}, []string{"host"})
+	// FragmentDuration is time a fragment request takes by cdn host and result, ok or error
+	FragmentDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fragment_duration_seconds",
+		Help:      "Time of downloading a fragment.",
+		Buckets:   []float64{.1, .25, .5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"host", "result"})
+	// FragmentRetries is fragments retried by cdn host
+	FragmentRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fragment_retries_total",
+		Help:      "Fragments downloaded again after failure.",
+	}, []string{"host"})
+	// APIRequests is api requests by path and code of response, code is error if response is not json
+	APIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Requests to bilibili api by code of response.",
+	}, []string{"path", "code"})
+	// APIDuration is time api requests take by path
+	APIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Time of requests to bilibili api.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"path"})
+	// Jobs is jobs of server by state, running ones are active and queued ones are depth of queue
+	Jobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs",
+		Help:      "Jobs of server by state.",
+	}, []string{"state"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DownloadedBytes,
+		FragmentDuration,
+		FragmentRetries,
+		APIRequests,
+		APIDuration,
+		Jobs,
+	)
+}
+
+// Handler serve metrics of Registry in prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
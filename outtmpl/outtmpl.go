@@ -0,0 +1,323 @@
+package outtmpl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+)
+
+const (
+	// DefaultTemplate keep names of previous versions, video id is added to avoid collision
+	DefaultTemplate = "{title} - {part} [{id}].{ext}"
+	// MaxNameBytes is name length limit of most filesystems
+	MaxNameBytes = 255
+	// SidecarReserve is bytes reserved for sidecar suffix like .zh-CN.srt/.info.json
+	SidecarReserve = 24
+)
+
+// Fields is values can be referenced in template
+type Fields map[string]interface{}
+
+// fieldDocs document every field, also used to validate template
+var fieldDocs = map[string]string{
+	"id":          "video id given by user, BV/av id or ep id for bangumi",
+	"bvid":        "BV id",
+	"avid":        "av id",
+	"cid":         "cid of page",
+	"ep_id":       "episode id of bangumi",
+	"season_id":   "season id of bangumi",
+	"title":       "title of video",
+	"part":        "part name of page",
+	"page":        "page no, episode no for bangumi",
+	"page_title":  "title, with part name appended when they differ",
+	"uploader":    "uploader name",
+	"mid":         "uploader id",
+	"tname":       "category name",
+	"pubdate":     "publish date like 2021-11-08",
+	"upload_date": "publish date like 20211108",
+	"duration":    "length in seconds",
+	"qn":          "quality number",
+	"ext":         "file extension, flv/mp4",
+}
+
+// intFields is fields of integer values, others are strings
+var intFields = map[string]bool{
+	"avid":      true,
+	"cid":       true,
+	"ep_id":     true,
+	"season_id": true,
+	"page":      true,
+	"mid":       true,
+	"duration":  true,
+	"qn":        true,
+}
+
+// FieldNames return all field names sorted
+func FieldNames() []string {
+	names := make([]string, 0, len(fieldDocs))
+	for k := range fieldDocs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FieldDoc return description of field
+func FieldDoc(name string) string {
+	return fieldDocs[name]
+}
+
+// FieldsOf collect fields of video page, info may be nil before playurl is queried
+func FieldsOf(video *download.VideoInfo, info *download.DownloadInfo) Fields {
+	f := Fields{
+		"id":          video.VideoID,
+		"avid":        video.Avid,
+		"cid":         video.Cid,
+		"ep_id":       video.EpID,
+		"title":       video.Title,
+		"part":        video.PartName,
+		"page":        video.Page,
+		"page_title":  video.Title,
+		"duration":    video.Duration,
+		"bvid":        "",
+		"season_id":   int64(0),
+		"uploader":    "",
+		"mid":         int64(0),
+		"tname":       "",
+		"pubdate":     "",
+		"upload_date": "",
+		"qn":          int64(0),
+		"ext":         "",
+	}
+	if video.PartName != "" && video.PartName != video.Title {
+		f["page_title"] = video.Title + " - " + video.PartName
+	}
+	if m := video.Meta; m != nil {
+		f["bvid"] = m.Bvid
+		f["season_id"] = m.SeasonID
+		f["uploader"] = m.Uploader
+		f["mid"] = m.Mid
+		f["tname"] = m.Tname
+		if m.Pubdate > 0 {
+			pub := m.PubTime().In(consts.CST)
+			f["pubdate"] = pub.Format("2006-01-02")
+			f["upload_date"] = pub.Format("20060102")
+		}
+	}
+	if info != nil {
+		f["qn"] = info.Qn
+		f["ext"] = info.Format
+	}
+	return f
+}
+
+type segment struct {
+	literal string
+	field   string
+	format  string // printf verb without %
+}
+
+// Template is a parsed output template like "{uploader}/{title} [{bvid}]/{page:02d} - {part}.{ext}",
+// "/" separates directories, "{{" and "}}" are literal braces
+type Template struct {
+	raw      string
+	segments []segment
+}
+
+var formatRe = regexp.MustCompile(`^[-+# 0]*[0-9]*(\.[0-9]+)?[dsvxXq]$`)
+
+// Parse parse output template
+func Parse(tmpl string) (*Template, error) {
+	t := &Template{raw: tmpl}
+	var lit strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '{' && i+1 < len(tmpl) && tmpl[i+1] == '{':
+			lit.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(tmpl) && tmpl[i+1] == '}':
+			lit.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed { at %v", i)
+			}
+			name, format := tmpl[i+1:i+end], ""
+			if idx := strings.IndexByte(name, ':'); idx != -1 {
+				name, format = name[:idx], name[idx+1:]
+				if !formatRe.MatchString(format) {
+					return nil, fmt.Errorf("invalid format %q of field %v", format, name)
+				}
+			}
+			if _, ok := fieldDocs[name]; !ok {
+				return nil, fmt.Errorf("unknown field %q, available fields: %v", name, strings.Join(FieldNames(), ", "))
+			}
+			if err := checkVerb(name, format); err != nil {
+				return nil, err
+			}
+			if lit.Len() != 0 {
+				t.segments = append(t.segments, segment{literal: lit.String()})
+				lit.Reset()
+			}
+			t.segments = append(t.segments, segment{field: name, format: format})
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unexpected } at %v", i)
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() != 0 {
+		t.segments = append(t.segments, segment{literal: lit.String()})
+	}
+	if len(t.segments) == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+	return t, nil
+}
+
+// checkVerb check if verb of format suits type of field
+func checkVerb(name, format string) error {
+	if format == "" {
+		return nil
+	}
+	verbs := "sqv"
+	if intFields[name] {
+		verbs = "dxXv"
+	}
+	if verb := format[len(format)-1:]; !strings.Contains(verbs, verb) {
+		return fmt.Errorf("invalid format %q of field %v, verb should be one of %v", format, name, verbs)
+	}
+	return nil
+}
+
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Options control how rendered name is sanitized
+type Options struct {
+	Sanitizer Sanitizer
+	MaxBytes  int // max bytes of a path component, MaxNameBytes if 0
+	Reserve   int // bytes reserved in file name for sidecar suffix, SidecarReserve if 0
+}
+
+// Execute render template to a file path, every field value is sanitized so values
+// never introduce directories, every component is truncated by bytes
+func (t *Template) Execute(fields Fields, opts *Options) (string, error) {
+	var (
+		sanitizer Sanitizer = SanitizerFunc(sanitizeWindows)
+		maxBytes            = MaxNameBytes
+		reserve             = SidecarReserve
+	)
+	if opts != nil {
+		if opts.Sanitizer != nil {
+			sanitizer = opts.Sanitizer
+		}
+		if opts.MaxBytes > 0 {
+			maxBytes = opts.MaxBytes
+		}
+		if opts.Reserve > 0 {
+			reserve = opts.Reserve
+		}
+	}
+
+	var sb strings.Builder
+	for _, seg := range t.segments {
+		if seg.field == "" {
+			sb.WriteString(seg.literal)
+			continue
+		}
+		v, ok := fields[seg.field]
+		if !ok {
+			return "", fmt.Errorf("field %v not provided", seg.field)
+		}
+		var s string
+		if seg.format != "" {
+			s = fmt.Sprintf("%"+seg.format, v)
+		} else {
+			s = fmt.Sprint(v)
+		}
+		// separators are always replaced, values never create directories
+		s = strings.NewReplacer("/", " ", `\`, " ").Replace(s)
+		sb.WriteString(sanitizer.Sanitize(s))
+	}
+
+	rendered := sb.String()
+	comps := strings.Split(rendered, "/")
+	out := make([]string, 0, len(comps))
+	for i, comp := range comps {
+		switch {
+		case comp == "" && i == 0:
+			// keep absolute path
+			out = append(out, "/")
+			continue
+		case comp == "" || comp == "." || comp == "..":
+			if comp != "" {
+				out = append(out, comp)
+			}
+			continue
+		}
+		if i == len(comps)-1 {
+			ext := filepath.Ext(comp)
+			limit := maxBytes - len(ext) - reserve
+			if limit < 1 {
+				limit = 1
+			}
+			comp = strings.TrimRight(truncateBytes(strings.TrimSuffix(comp, ext), limit), " ") + ext
+		} else {
+			comp = strings.TrimRight(truncateBytes(comp, maxBytes), " ")
+		}
+		if comp == "" {
+			comp = "_"
+		}
+		out = append(out, comp)
+	}
+	if len(out) == 0 || strings.HasSuffix(rendered, "/") {
+		return "", fmt.Errorf("template %q renders no file name", t.raw)
+	}
+	return filepath.Join(out...), nil
+}
+
+// truncateBytes cut s to at most n bytes without breaking utf-8 characters
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Prepare render template and create parent directories of the file
+func (t *Template) Prepare(fields Fields, opts *Options) (string, error) {
+	name, err := t.Execute(fields, opts)
+	if err != nil {
+		return "", err
+	}
+	if dir := filepath.Dir(name); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// Doc return help text of template fields
+func Doc() string {
+	var sb strings.Builder
+	for _, name := range FieldNames() {
+		sb.WriteString("  {" + name + "}: " + fieldDocs[name] + "\n")
+	}
+	return sb.String()
+}
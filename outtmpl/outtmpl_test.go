@@ -0,0 +1,104 @@
+package outtmpl
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/stretchr/testify/require"
+)
+
+func testFields() Fields {
+	video := &download.VideoInfo{
+		VideoID:  "BV1pP4y1b7iP",
+		Avid:     891245009,
+		Cid:      428280666,
+		Title:    "标题: a/b|c?",
+		Page:     3,
+		Duration: 100,
+		PartName: "第三集",
+		Meta: &download.VideoMeta{
+			Bvid:     "BV1pP4y1b7iP",
+			Uploader: "up*主",
+			Pubdate:  1636300800,
+		},
+	}
+	return FieldsOf(video, &download.DownloadInfo{Qn: 80, Format: "flv"})
+}
+
+func TestParse(t *testing.T) {
+	for _, tmpl := range []string{"{title", "{unknown}", "{page:02z}", "{title:05d}", "{page:s}", "{cid:q}", "a}b", ""} {
+		_, err := Parse(tmpl)
+		require.NotNil(t, err, tmpl)
+	}
+	for _, tmpl := range []string{DefaultTemplate, "{page:03d}", "{title:.10s}", "{cid:x}", "{qn:v}"} {
+		_, err := Parse(tmpl)
+		require.Nil(t, err, tmpl)
+	}
+	// verbs are checked by types of values
+	for name, v := range testFields() {
+		_, isInt := v.(int64)
+		require.Equal(t, intFields[name], isInt, name)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	windows, _ := GetSanitizer("windows")
+	posix, _ := GetSanitizer("posix")
+	strict, _ := GetSanitizer("strict")
+
+	cases := []struct {
+		tmpl   string
+		opts   *Options
+		expect string
+	}{
+		{DefaultTemplate, nil, "标题 a b c - 第三集 [BV1pP4y1b7iP].flv"},
+		{"{uploader}/{title} [{bvid}]/{page:02d} - {part}.{ext}", &Options{Sanitizer: windows},
+			filepath.Join("up 主", "标题 a b c [BV1pP4y1b7iP]", "03 - 第三集.flv")},
+		{"{title}.{ext}", &Options{Sanitizer: posix}, "标题: a b|c?.flv"},
+		{"{upload_date}_{{{avid}}}.{ext}", &Options{Sanitizer: strict}, "20211108_{891245009}.flv"},
+		{"/data/{pubdate}/{cid:x}.{ext}", nil, "/data/2021-11-08/19870b5a.flv"},
+		{"../{page_title}.{ext}", nil, filepath.Join("..", "标题 a b c - 第三集.flv")},
+	}
+	for _, c := range cases {
+		tmpl, err := Parse(c.tmpl)
+		require.Nil(t, err, c.tmpl)
+		name, err := tmpl.Execute(testFields(), c.opts)
+		require.Nil(t, err, c.tmpl)
+		require.Equal(t, c.expect, name, c.tmpl)
+	}
+
+	tmpl, _ := Parse("{title}/")
+	_, err := tmpl.Execute(testFields(), nil)
+	require.NotNil(t, err)
+}
+
+func TestTruncate(t *testing.T) {
+	fields := testFields()
+	fields["title"] = strings.Repeat("长", 200) // 600 bytes
+	tmpl, _ := Parse("{title}/{title}.{ext}")
+	name, err := tmpl.Execute(fields, nil)
+	require.Nil(t, err)
+
+	dir, file := filepath.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+	require.LessOrEqual(t, len(dir), MaxNameBytes)
+	require.Equal(t, strings.Repeat("长", 85), dir)
+	require.LessOrEqual(t, len(file), MaxNameBytes-SidecarReserve)
+	require.True(t, strings.HasSuffix(file, "长.flv"))
+}
+
+func TestSanitizer(t *testing.T) {
+	windows, _ := GetSanitizer("windows")
+	require.Equal(t, "_CON", windows.Sanitize("CON"))
+	require.Equal(t, "_aux.txt", windows.Sanitize("aux.txt"))
+	require.Equal(t, "a b", windows.Sanitize(" a<>b... "))
+	require.Equal(t, "_", windows.Sanitize("..."))
+
+	strict, _ := GetSanitizer("strict")
+	require.Equal(t, "a b c", strict.Sanitize("-a#b＂c"))
+
+	_, err := GetSanitizer("fat")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,109 @@
+package outtmpl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Sanitizer make a path component safe for a kind of filesystem
+type Sanitizer interface {
+	Sanitize(name string) string
+}
+
+// SanitizerFunc adapt function to Sanitizer
+type SanitizerFunc func(name string) string
+
+func (f SanitizerFunc) Sanitize(name string) string {
+	return f(name)
+}
+
+var profiles = map[string]Sanitizer{
+	"posix":   SanitizerFunc(sanitizePosix),
+	"windows": SanitizerFunc(sanitizeWindows),
+	"strict":  SanitizerFunc(sanitizeStrict),
+}
+
+// Profiles return names of sanitizer profiles
+func Profiles() []string {
+	return []string{"posix", "windows", "strict"}
+}
+
+// GetSanitizer get sanitizer by profile name:
+// posix only removes path separator and control characters,
+// windows also removes characters and names reserved by windows/smb,
+// strict also removes shell and url special characters
+func GetSanitizer(profile string) (Sanitizer, error) {
+	s, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown sanitizer profile %q, should be one of %v", profile, Profiles())
+	}
+	return s, nil
+}
+
+func replaceRunes(name string, bad func(r rune) bool) string {
+	var (
+		sb    strings.Builder
+		space bool
+	)
+	for _, r := range name {
+		if bad(r) || unicode.IsSpace(r) {
+			// collapse replaced characters and spaces into one space
+			if !space {
+				sb.WriteRune(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func sanitizePosix(name string) string {
+	name = replaceRunes(name, func(r rune) bool {
+		return r == '/' || unicode.IsControl(r)
+	})
+	if name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func sanitizeWindows(name string) string {
+	name = replaceRunes(name, func(r rune) bool {
+		return strings.ContainsRune(`<>:"/\|?*`, r) || unicode.IsControl(r)
+	})
+	// trailing dots and spaces are stripped by windows
+	name = strings.TrimRight(name, ". ")
+	stem := name
+	if idx := strings.IndexByte(stem, '.'); idx != -1 {
+		stem = stem[:idx]
+	}
+	if windowsReserved[strings.ToUpper(strings.TrimSpace(stem))] {
+		name = "_" + name
+	}
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+func sanitizeStrict(name string) string {
+	name = replaceRunes(name, func(r rune) bool {
+		return strings.ContainsRune("#%&{}$!'`@+=;,~^[]", r) ||
+			// full width variants of special characters are also rejected by some nas
+			strings.ContainsRune("＜＞：＂／＼｜？＊", r)
+	})
+	name = strings.TrimLeft(name, "-.")
+	return sanitizeWindows(name)
+}
@@ -0,0 +1,249 @@
+package postproc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errShortConfig is returned if codec config ends early
+var errShortConfig = errors.New("codec config too short")
+
+// bitReader read bits of rbsp from the most significant one
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+}
+
+func (r *bitReader) bit() (uint32, error) {
+	if r.pos >= len(r.data)*8 {
+		return 0, errShortConfig
+	}
+	b := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint32(b), nil
+}
+
+func (r *bitReader) bits(n int) (uint32, error) {
+	var v uint32
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+// ue read unsigned exp-golomb code
+func (r *bitReader) ue() (uint32, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errors.New("invalid exp-golomb code")
+		}
+	}
+	v, err := r.bits(zeros)
+	return 1<<zeros - 1 + v, err
+}
+
+// se read signed exp-golomb code
+func (r *bitReader) se() (int32, error) {
+	v, err := r.ue()
+	if v%2 == 1 {
+		return int32(v/2 + 1), err
+	}
+	return -int32(v / 2), err
+}
+
+// unescape remove emulation prevention bytes of nal unit
+func unescape(nal []byte) []byte {
+	rbsp := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
+
+// avcSize get width and height of picture from the first sps of AVCDecoderConfigurationRecord
+func avcSize(avcC []byte) (width, height uint32, err error) {
+	if len(avcC) < 8 || avcC[0] != 1 {
+		return 0, 0, errors.New("invalid avc decoder config")
+	}
+	if avcC[5]&0x1f == 0 {
+		return 0, 0, errors.New("no sps in avc decoder config")
+	}
+	n := int(avcC[6])<<8 | int(avcC[7])
+	if len(avcC) < 8+n || n < 4 {
+		return 0, 0, errShortConfig
+	}
+	return spsSize(avcC[8 : 8+n])
+}
+
+// spsSize parse sequence parameter set nal unit for size of picture with cropping applied
+func spsSize(nal []byte) (width, height uint32, err error) {
+	r := &bitReader{data: unescape(nal[1:])}
+	profile, err := r.bits(8)
+	if err != nil {
+		return 0, 0, err
+	}
+	// constraint flags and level
+	r.bits(16)
+	r.ue() // seq_parameter_set_id
+
+	chroma := uint32(1)
+	separate := uint32(0)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chroma, _ = r.ue()
+		if chroma == 3 {
+			separate, _ = r.bit()
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		present, err := r.bit()
+		if err != nil {
+			return 0, 0, err
+		}
+		if present == 1 {
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if p, _ := r.bit(); p == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(r, size)
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+	pocType, _ := r.ue()
+	switch pocType {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit() // delta_pic_order_always_zero_flag
+		r.se()  // offset_for_non_ref_pic
+		r.se()  // offset_for_top_to_bottom_field
+		cycle, _ := r.ue()
+		for i := uint32(0); i < cycle; i++ {
+			r.se()
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+	widthMbs, _ := r.ue()
+	heightMaps, _ := r.ue()
+	frameMbsOnly, _ := r.bit()
+	if frameMbsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+	cropping, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	var left, right, top, bottom uint32
+	if cropping == 1 {
+		left, _ = r.ue()
+		right, _ = r.ue()
+		top, _ = r.ue()
+		bottom, err = r.ue()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// crop units by chroma format, see 7.4.2.1.1 of h.264
+	cropX, cropY := uint32(1), 2-frameMbsOnly
+	if chroma != 0 && separate == 0 {
+		subWidth, subHeight := uint32(2), uint32(2)
+		switch chroma {
+		case 2:
+			subHeight = 1
+		case 3:
+			subWidth, subHeight = 1, 1
+		}
+		cropX, cropY = subWidth, subHeight*(2-frameMbsOnly)
+	}
+	width = (widthMbs+1)*16 - (left+right)*cropX
+	height = (2-frameMbsOnly)*(heightMaps+1)*16 - (top+bottom)*cropY
+	return width, height, nil
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size; i++ {
+		if next != 0 {
+			delta, _ := r.se()
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// aacRates are sampling frequencies by index of AudioSpecificConfig
+var aacRates = []uint32{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// aacConfig get sample rate and channels from AudioSpecificConfig
+func aacConfig(asc []byte) (rate, channels uint32, err error) {
+	r := &bitReader{data: asc}
+	objectType, err := r.bits(5)
+	if err != nil {
+		return 0, 0, err
+	}
+	if objectType == 31 {
+		r.bits(6)
+	}
+	index, err := r.bits(4)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case index == 15:
+		rate, err = r.bits(24)
+	case int(index) < len(aacRates):
+		rate = aacRates[index]
+	default:
+		return 0, 0, fmt.Errorf("invalid aac sampling frequency index %v", index)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	channels, err = r.bits(4)
+	if err != nil {
+		return 0, 0, err
+	}
+	if channels == 0 {
+		// channels are given by program config element, stereo is the usual one
+		channels = 2
+	}
+	return rate, channels, nil
+}
@@ -0,0 +1,124 @@
+package postproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+// ErrNoFFmpeg is returned when ffmpeg can not be found in PATH
+var ErrNoFFmpeg = errors.New("ffmpeg not found in PATH")
+
+// Subtitle is a subtitle file to embed
+type Subtitle struct {
+	File  string
+	Lang  string // ISO 639-2 language code
+	Title string
+}
+
+// EmbedOptions is what to embed into a mp4 file
+type EmbedOptions struct {
+	Subtitles []*Subtitle
+	Metadata  map[string]string // global tags like title/artist/date
+	Cover     string            // cover image file, attached as picture
+	Chapters  string            // ffmpeg metadata file with chapters
+}
+
+func (o *EmbedOptions) empty() bool {
+	return len(o.Subtitles) == 0 && len(o.Metadata) == 0 && o.Cover == "" && o.Chapters == ""
+}
+
+// Embed embed subtitles, tags, cover and chapters into mp4 file in place, streams are copied without re-encoding
+func Embed(video string, opts *EmbedOptions) error {
+	if opts == nil || opts.empty() {
+		return nil
+	}
+	if strings.ToLower(filepath.Ext(video)) != ".mp4" {
+		return fmt.Errorf("embed only support mp4 file, got %v", video)
+	}
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return ErrNoFFmpeg
+	}
+
+	tmp := strings.TrimSuffix(video, filepath.Ext(video)) + ".embed.tmp.mp4"
+	args := buildArgs(video, tmp, opts)
+	log.Debugf("run ffmpeg %v", args)
+
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command(bin, args...)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("run ffmpeg error: %v, output: %s", err, lastLines(stderr.String(), 5))
+	}
+
+	return os.Rename(tmp, video)
+}
+
+func buildArgs(video, out string, opts *EmbedOptions) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", video}
+	for _, sub := range opts.Subtitles {
+		args = append(args, "-i", sub.File)
+	}
+	if opts.Cover != "" {
+		args = append(args, "-i", opts.Cover)
+	}
+	if opts.Chapters != "" {
+		args = append(args, "-i", opts.Chapters)
+	}
+	args = append(args, "-map", "0")
+	for i := range opts.Subtitles {
+		args = append(args, "-map", strconv.Itoa(i+1))
+	}
+	if opts.Cover != "" {
+		args = append(args, "-map", strconv.Itoa(len(opts.Subtitles)+1))
+	}
+	if opts.Chapters != "" {
+		idx := len(opts.Subtitles) + 1
+		if opts.Cover != "" {
+			idx++
+		}
+		args = append(args, "-map_chapters", strconv.Itoa(idx))
+	}
+	args = append(args, "-c", "copy")
+	if len(opts.Subtitles) != 0 {
+		args = append(args, "-c:s", "mov_text")
+	}
+	if opts.Cover != "" {
+		args = append(args, "-disposition:v:1", "attached_pic")
+	}
+	keys := make([]string, 0, len(opts.Metadata))
+	for k := range opts.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-metadata", k+"="+opts.Metadata[k])
+	}
+	for i, sub := range opts.Subtitles {
+		if sub.Lang != "" {
+			args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "language="+sub.Lang)
+		}
+		if sub.Title != "" {
+			args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "title="+sub.Title)
+		}
+	}
+	return append(args, out)
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,50 @@
+package postproc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildArgs(t *testing.T) {
+	args := buildArgs("a.mp4", "a.tmp.mp4", &EmbedOptions{
+		Subtitles: []*Subtitle{
+			{File: "a.zh-CN.srt", Lang: "chi", Title: "中文"},
+			{File: "a.en-US.srt", Lang: "eng"},
+		},
+	})
+	require.Equal(t, []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", "a.mp4", "-i", "a.zh-CN.srt", "-i", "a.en-US.srt",
+		"-map", "0", "-map", "1", "-map", "2",
+		"-c", "copy", "-c:s", "mov_text",
+		"-metadata:s:s:0", "language=chi", "-metadata:s:s:0", "title=中文",
+		"-metadata:s:s:1", "language=eng",
+		"a.tmp.mp4",
+	}, args)
+}
+
+func TestBuildArgsMeta(t *testing.T) {
+	args := buildArgs("a.mp4", "a.tmp.mp4", &EmbedOptions{
+		Metadata: map[string]string{
+			"title":  "标题",
+			"artist": "up",
+		},
+		Cover:    "a.jpg",
+		Chapters: "a.ffmetadata",
+	})
+	require.Equal(t, []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", "a.mp4", "-i", "a.jpg", "-i", "a.ffmetadata",
+		"-map", "0", "-map", "1", "-map_chapters", "2",
+		"-c", "copy", "-disposition:v:1", "attached_pic",
+		"-metadata", "artist=up", "-metadata", "title=标题",
+		"a.tmp.mp4",
+	}, args)
+}
+
+func TestEmbedNotMp4(t *testing.T) {
+	err := Embed("a.flv", &EmbedOptions{Subtitles: []*Subtitle{{File: "a.srt"}}})
+	require.NotNil(t, err)
+	require.Nil(t, Embed("a.flv", &EmbedOptions{}))
+}
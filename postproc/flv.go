@@ -0,0 +1,206 @@
+package postproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	flvTagAudio  = 8
+	flvTagVideo  = 9
+	flvTagScript = 18
+
+	flvCodecAVC = 7
+	flvCodecAAC = 10
+
+	flvKeyFrame  = 1
+	flvInfoFrame = 5
+)
+
+// ErrUnsupported is returned if codec of flv is not h.264 or aac
+var ErrUnsupported = errors.New("unsupported codec")
+
+// sample is a frame in flv, data is copied from flv when mp4 is written
+type sample struct {
+	off  int64 // offset of data in flv
+	size uint32
+	dts  int64 // milliseconds
+	cts  int32 // composition offset of video in milliseconds
+	key  bool
+}
+
+// flvTrack is config and frames of a codec in flv
+type flvTrack struct {
+	config  []byte // AVCDecoderConfigurationRecord or AudioSpecificConfig
+	samples []sample
+}
+
+// flvReader read tags of flv in order
+type flvReader struct {
+	r   *bufio.Reader
+	off int64
+}
+
+// read n bytes, io.EOF is returned only if no byte is left
+func (r *flvReader) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, err
+	}
+	r.off += int64(n)
+	return buf, nil
+}
+
+func (r *flvReader) skip(n int) error {
+	d, err := r.r.Discard(n)
+	r.off += int64(d)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readFLV read frames of h.264 video and aac audio of flv, audio is nil if flv has none
+func readFLV(src io.Reader) (video, audio *flvTrack, err error) {
+	r := &flvReader{r: bufio.NewReaderSize(src, 64*1024)}
+	head, err := r.read(9)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read flv header error: %w", err)
+	}
+	if string(head[:3]) != "FLV" {
+		return nil, nil, errors.New("not a flv file")
+	}
+	if err := r.skip(int(binary.BigEndian.Uint32(head[5:9])) - 9 + 4); err != nil {
+		return nil, nil, fmt.Errorf("read flv header error: %w", err)
+	}
+
+	video, audio = &flvTrack{}, &flvTrack{}
+	for {
+		tag, err := r.read(11)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read tag at offset %v error: %w", r.off, err)
+		}
+		var (
+			typ  = tag[0] & 0x1f
+			size = int(tag[1])<<16 | int(tag[2])<<8 | int(tag[3])
+			dts  = int64(tag[4])<<16 | int64(tag[5])<<8 | int64(tag[6]) | int64(tag[7])<<24
+			off  = r.off
+		)
+		switch typ {
+		case flvTagVideo:
+			err = readVideoTag(r, video, size, dts)
+		case flvTagAudio:
+			err = readAudioTag(r, audio, size, dts)
+		case flvTagScript:
+			err = r.skip(size)
+		default:
+			err = fmt.Errorf("invalid tag type %v", typ)
+		}
+		if err == nil {
+			// previous tag size
+			err = r.skip(4)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read tag at offset %v error: %w", off-11, err)
+		}
+	}
+
+	if video.config == nil {
+		return nil, nil, errors.New("no h.264 video in flv")
+	}
+	if audio.config == nil {
+		audio = nil
+	}
+	return video, audio, nil
+}
+
+func readVideoTag(r *flvReader, t *flvTrack, size int, dts int64) error {
+	if size == 0 {
+		return nil
+	}
+	b, err := r.read(1)
+	if err != nil {
+		return err
+	}
+	frame, codec := b[0]>>4, b[0]&0x0f
+	if frame == flvInfoFrame {
+		return r.skip(size - 1)
+	}
+	if codec != flvCodecAVC {
+		return fmt.Errorf("%w: video codec id %v", ErrUnsupported, codec)
+	}
+	if size < 5 {
+		return fmt.Errorf("avc packet of %v bytes is too small", size)
+	}
+	head, err := r.read(4)
+	if err != nil {
+		return err
+	}
+	// composition time is signed 24 bits
+	cts := int32(uint32(head[1])<<24|uint32(head[2])<<16|uint32(head[3])<<8) >> 8
+	switch head[0] {
+	case 0:
+		config, err := r.read(size - 5)
+		if err != nil {
+			return err
+		}
+		return setConfig(t, config, "video")
+	case 1:
+		if size > 5 {
+			t.samples = append(t.samples, sample{off: r.off, size: uint32(size - 5), dts: dts, cts: cts, key: frame == flvKeyFrame})
+		}
+	}
+	return r.skip(size - 5)
+}
+
+func readAudioTag(r *flvReader, t *flvTrack, size int, dts int64) error {
+	if size == 0 {
+		return nil
+	}
+	b, err := r.read(1)
+	if err != nil {
+		return err
+	}
+	if codec := b[0] >> 4; codec != flvCodecAAC {
+		return fmt.Errorf("%w: audio codec id %v", ErrUnsupported, codec)
+	}
+	if size < 2 {
+		return fmt.Errorf("aac packet of %v bytes is too small", size)
+	}
+	b, err = r.read(1)
+	if err != nil {
+		return err
+	}
+	switch b[0] {
+	case 0:
+		config, err := r.read(size - 2)
+		if err != nil {
+			return err
+		}
+		return setConfig(t, config, "audio")
+	case 1:
+		if size > 2 {
+			t.samples = append(t.samples, sample{off: r.off, size: uint32(size - 2), dts: dts, key: true})
+		}
+	}
+	return r.skip(size - 2)
+}
+
+// setConfig keep config of track, sequence headers repeated are fine but changed ones can't be put in a single track
+func setConfig(t *flvTrack, config []byte, kind string) error {
+	if t.config != nil && !bytes.Equal(t.config, config) {
+		return fmt.Errorf("%w: %v config changes in stream", ErrUnsupported, kind)
+	}
+	t.config = config
+	return nil
+}
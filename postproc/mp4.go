@@ -0,0 +1,375 @@
+package postproc
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	movieTimescale = 1000 // timescale of movie and video, same as flv timestamps
+	aacFrameSize   = 1024 // samples of an aac frame
+)
+
+// builder append big endian fields of box payload
+type builder []byte
+
+func (b *builder) u8(v uint8)   { *b = append(*b, v) }
+func (b *builder) u16(v uint16) { *b = append(*b, byte(v>>8), byte(v)) }
+func (b *builder) u24(v uint32) { *b = append(*b, byte(v>>16), byte(v>>8), byte(v)) }
+func (b *builder) u32(v uint32) { *b = append(*b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)) }
+func (b *builder) u64(v uint64) { b.u32(uint32(v >> 32)); b.u32(uint32(v)) }
+func (b *builder) zeros(n int)  { *b = append(*b, make([]byte, n)...) }
+func (b *builder) raw(p []byte) { *b = append(*b, p...) }
+
+// matrix is the identity transformation of mvhd and tkhd
+func (b *builder) matrix() {
+	for _, v := range []uint32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000} {
+		b.u32(v)
+	}
+}
+
+// box build box of type with payloads concatenated
+func box(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, p := range payloads {
+		size += len(p)
+	}
+	b := make(builder, 0, size)
+	b.u32(uint32(size))
+	b.raw([]byte(typ))
+	for _, p := range payloads {
+		b.raw(p)
+	}
+	return b
+}
+
+// fullBox build box with version and flags
+func fullBox(typ string, version uint8, flags uint32, payloads ...[]byte) []byte {
+	head := builder{}
+	head.u8(version)
+	head.u24(flags)
+	return box(typ, append([][]byte{head}, payloads...)...)
+}
+
+// mp4Track is a track of mp4 made of samples of a flv track
+type mp4Track struct {
+	id        uint32
+	handler   string // vide or soun
+	timescale uint32
+	entry     []byte // sample entry of stsd
+	width     uint32
+	height    uint32
+
+	samples   []sample
+	offsets   []int64  // offsets of samples in mp4
+	durations []uint32 // in timescale
+	start     int64    // dts of the first sample in milliseconds, the track is delayed by an empty edit
+	duration  int64    // in timescale
+}
+
+// movieDuration return duration of track in movie timescale, the empty edit included
+func (t *mp4Track) movieDuration() int64 {
+	return t.start + scale(t.duration, t.timescale, movieTimescale)
+}
+
+func scale(v int64, from, to uint32) int64 {
+	return int64(math.Round(float64(v) * float64(to) / float64(from)))
+}
+
+// setTiming compute durations of samples from their dts, the last one lasts as long as the one before it.
+// fixed is duration of every sample if known like aac frames, dts in milliseconds are rounded
+// so deltas within a millisecond of it are taken as it
+func (t *mp4Track) setTiming(fixed uint32) {
+	n := len(t.samples)
+	t.durations = make([]uint32, n)
+	if n == 0 {
+		return
+	}
+	t.start = t.samples[0].dts
+	tolerance := int64(t.timescale)/movieTimescale + 1
+	last := int64(fixed)
+	for i := 0; i < n-1; i++ {
+		delta := scale(t.samples[i+1].dts, movieTimescale, t.timescale) - scale(t.samples[i].dts, movieTimescale, t.timescale)
+		if delta < 0 {
+			// dts never go back in mp4
+			delta = 0
+		}
+		if fixed > 0 && math.Abs(float64(delta-int64(fixed))) <= float64(tolerance) {
+			delta = int64(fixed)
+		}
+		t.durations[i] = uint32(delta)
+		last = delta
+	}
+	t.durations[n-1] = uint32(last)
+	t.duration = 0
+	for _, d := range t.durations {
+		t.duration += int64(d)
+	}
+}
+
+// trak build trak box of track, chunk offsets are 64 bits if co64 is set
+func (t *mp4Track) trak(co64 bool) []byte {
+	tkhd := builder{}
+	tkhd.u32(0) // creation time
+	tkhd.u32(0) // modification time
+	tkhd.u32(t.id)
+	tkhd.u32(0)
+	tkhd.u32(uint32(t.movieDuration()))
+	tkhd.zeros(8)
+	tkhd.u16(0) // layer
+	tkhd.u16(0) // alternate group
+	if t.handler == "soun" {
+		tkhd.u16(0x0100)
+	} else {
+		tkhd.u16(0)
+	}
+	tkhd.u16(0)
+	tkhd.matrix()
+	tkhd.u32(t.width << 16)
+	tkhd.u32(t.height << 16)
+
+	boxes := [][]byte{fullBox("tkhd", 0, 3, tkhd)}
+	if t.start > 0 {
+		elst := builder{}
+		elst.u32(2)
+		// nothing is shown before the first sample
+		elst.u32(uint32(t.start))
+		elst.u32(math.MaxUint32) // media time -1
+		elst.u32(0x10000)
+		elst.u32(uint32(scale(t.duration, t.timescale, movieTimescale)))
+		elst.u32(0)
+		elst.u32(0x10000)
+		boxes = append(boxes, box("edts", fullBox("elst", 0, 0, elst)))
+	}
+
+	mdhd := builder{}
+	mdhd.u32(0)
+	mdhd.u32(0)
+	mdhd.u32(t.timescale)
+	mdhd.u32(uint32(t.duration))
+	mdhd.u16(0x55c4) // und
+	mdhd.u16(0)
+
+	hdlr := builder{}
+	hdlr.u32(0)
+	hdlr.raw([]byte(t.handler))
+	hdlr.zeros(12)
+	var header []byte
+	if t.handler == "soun" {
+		hdlr.raw([]byte("SoundHandler\x00"))
+		header = fullBox("smhd", 0, 0, make([]byte, 4))
+	} else {
+		hdlr.raw([]byte("VideoHandler\x00"))
+		header = fullBox("vmhd", 0, 1, make([]byte, 8))
+	}
+
+	dref := builder{}
+	dref.u32(1)
+	dref.raw(fullBox("url ", 0, 1))
+
+	minf := box("minf", header, box("dinf", fullBox("dref", 0, 0, dref)), t.stbl(co64))
+	boxes = append(boxes, box("mdia", fullBox("mdhd", 0, 0, mdhd), fullBox("hdlr", 0, 0, hdlr), minf))
+	return box("trak", boxes...)
+}
+
+// stbl build sample table, every sample is a chunk as samples of tracks are interleaved in flv order
+func (t *mp4Track) stbl(co64 bool) []byte {
+	stsd := builder{}
+	stsd.u32(1)
+	stsd.raw(t.entry)
+
+	stts := builder{}
+	runs := 0
+	stts.u32(0)
+	for i := 0; i < len(t.durations); {
+		j := i
+		for j < len(t.durations) && t.durations[j] == t.durations[i] {
+			j++
+		}
+		stts.u32(uint32(j - i))
+		stts.u32(t.durations[i])
+		runs++
+		i = j
+	}
+	binary.BigEndian.PutUint32(stts[:4], uint32(runs))
+	boxes := [][]byte{fullBox("stsd", 0, 0, stsd), fullBox("stts", 0, 0, stts)}
+
+	if ctts, ok := t.ctts(); ok {
+		boxes = append(boxes, ctts)
+	}
+
+	allKey := true
+	stss := builder{}
+	stss.u32(0)
+	keys := 0
+	for i, s := range t.samples {
+		if s.key {
+			stss.u32(uint32(i + 1))
+			keys++
+		} else {
+			allKey = false
+		}
+	}
+	binary.BigEndian.PutUint32(stss[:4], uint32(keys))
+	if !allKey {
+		// every sample is a sync sample if stss is missing
+		boxes = append(boxes, fullBox("stss", 0, 0, stss))
+	}
+
+	stsc := builder{}
+	stsc.u32(1)
+	stsc.u32(1) // first chunk
+	stsc.u32(1) // samples per chunk
+	stsc.u32(1) // sample description index
+
+	stsz := builder{}
+	stsz.u32(0)
+	stsz.u32(uint32(len(t.samples)))
+	for _, s := range t.samples {
+		stsz.u32(s.size)
+	}
+
+	stco := builder{}
+	stco.u32(uint32(len(t.samples)))
+	for i := range t.samples {
+		var off int64
+		if t.offsets != nil {
+			off = t.offsets[i]
+		}
+		if co64 {
+			stco.u64(uint64(off))
+		} else {
+			stco.u32(uint32(off))
+		}
+	}
+	name := "stco"
+	if co64 {
+		name = "co64"
+	}
+	boxes = append(boxes, fullBox("stsc", 0, 0, stsc), fullBox("stsz", 0, 0, stsz), fullBox(name, 0, 0, stco))
+	return box("stbl", boxes...)
+}
+
+// ctts build composition offsets of video, false if all of them are 0
+func (t *mp4Track) ctts() ([]byte, bool) {
+	var (
+		nonzero bool
+		version uint8
+	)
+	for _, s := range t.samples {
+		if s.cts != 0 {
+			nonzero = true
+		}
+		if s.cts < 0 {
+			version = 1
+		}
+	}
+	if !nonzero {
+		return nil, false
+	}
+	b := builder{}
+	runs := 0
+	b.u32(0)
+	for i := 0; i < len(t.samples); {
+		j := i
+		for j < len(t.samples) && t.samples[j].cts == t.samples[i].cts {
+			j++
+		}
+		b.u32(uint32(j - i))
+		b.u32(uint32(scale(int64(t.samples[i].cts), movieTimescale, t.timescale)))
+		runs++
+		i = j
+	}
+	binary.BigEndian.PutUint32(b[:4], uint32(runs))
+	return fullBox("ctts", version, 0, b), true
+}
+
+// avc1 build sample entry of h.264 video
+func avc1(width, height uint32, avcC []byte) []byte {
+	b := builder{}
+	b.zeros(6)
+	b.u16(1) // data reference index
+	b.zeros(16)
+	b.u16(uint16(width))
+	b.u16(uint16(height))
+	b.u32(0x00480000) // 72 dpi
+	b.u32(0x00480000)
+	b.u32(0)
+	b.u16(1) // frame count
+	b.zeros(32)
+	b.u16(0x0018)
+	b.u16(0xffff)
+	return box("avc1", b, box("avcC", avcC))
+}
+
+// mp4a build sample entry of aac audio
+func mp4a(rate, channels uint32, asc []byte, trackID uint32) []byte {
+	b := builder{}
+	b.zeros(6)
+	b.u16(1) // data reference index
+	b.zeros(8)
+	b.u16(uint16(channels))
+	b.u16(16)
+	b.zeros(4)
+	if rate > math.MaxUint16 {
+		b.u32(0)
+	} else {
+		b.u32(rate << 16)
+	}
+
+	dcd := builder{}
+	dcd.u8(0x40) // mpeg-4 audio
+	dcd.u8(0x15) // audio stream
+	dcd.u24(0)   // buffer size
+	dcd.u32(0)   // max bitrate
+	dcd.u32(0)   // average bitrate
+	dcd.raw(descriptor(0x05, asc))
+
+	es := builder{}
+	es.u16(uint16(trackID))
+	es.u8(0)
+	es.raw(descriptor(0x04, dcd))
+	es.raw(descriptor(0x06, []byte{0x02}))
+	return box("mp4a", b, fullBox("esds", 0, 0, descriptor(0x03, es)))
+}
+
+// descriptor build mpeg-4 descriptor, size is coded in 7 bits groups
+func descriptor(tag uint8, payload []byte) []byte {
+	b := builder{tag}
+	var size []byte
+	n := len(payload)
+	size = append(size, byte(n&0x7f))
+	for n >>= 7; n > 0; n >>= 7 {
+		size = append([]byte{byte(n&0x7f) | 0x80}, size...)
+	}
+	b.raw(size)
+	b.raw(payload)
+	return b
+}
+
+// moov build movie box of tracks
+func moov(tracks []*mp4Track, co64 bool) []byte {
+	var duration int64
+	for _, t := range tracks {
+		if d := t.movieDuration(); d > duration {
+			duration = d
+		}
+	}
+	mvhd := builder{}
+	mvhd.u32(0)
+	mvhd.u32(0)
+	mvhd.u32(movieTimescale)
+	mvhd.u32(uint32(duration))
+	mvhd.u32(0x10000) // rate
+	mvhd.u16(0x0100)  // volume
+	mvhd.zeros(10)
+	mvhd.matrix()
+	mvhd.zeros(24)
+	mvhd.u32(uint32(len(tracks) + 1))
+
+	boxes := [][]byte{fullBox("mvhd", 0, 0, mvhd)}
+	for _, t := range tracks {
+		boxes = append(boxes, t.trak(co64))
+	}
+	return box("moov", boxes...)
+}
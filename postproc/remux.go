@@ -0,0 +1,167 @@
+package postproc
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+// ftyp is brands of mp4 written by remux
+var ftyp = box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41"))
+
+// RemuxFLV copy h.264 video and aac audio of flv file into mp4 file out without re-encoding,
+// timestamps and keyframes are kept, moov is put before mdat so the file plays while loading
+func RemuxFLV(in, out string) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	video, audio, err := readFLV(src)
+	if err != nil {
+		return err
+	}
+	tracks, err := buildTracks(video, audio)
+	if err != nil {
+		return err
+	}
+	header, order := layout(tracks)
+
+	tmp := strings.TrimSuffix(out, filepath.Ext(out)) + ".remux.tmp.mp4"
+	if err := writeMP4(src, tmp, header, order); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, out)
+}
+
+// buildTracks make mp4 tracks of flv tracks, audio may be nil
+func buildTracks(video, audio *flvTrack) ([]*mp4Track, error) {
+	width, height, err := avcSize(video.config)
+	if err != nil {
+		// size in tkhd is for display only, decoders read sps themselves
+		log.Warnf("parse sps error: %v", err)
+	}
+	vt := &mp4Track{
+		id:        1,
+		handler:   "vide",
+		timescale: movieTimescale,
+		entry:     avc1(width, height, video.config),
+		width:     width,
+		height:    height,
+		samples:   video.samples,
+	}
+	vt.setTiming(0)
+	tracks := []*mp4Track{vt}
+	if audio == nil {
+		return tracks, nil
+	}
+
+	rate, channels, err := aacConfig(audio.config)
+	if err != nil {
+		return nil, fmt.Errorf("parse aac config error: %w", err)
+	}
+	at := &mp4Track{
+		id:        2,
+		handler:   "soun",
+		timescale: rate,
+		entry:     mp4a(rate, channels, audio.config, 2),
+		samples:   audio.samples,
+	}
+	at.setTiming(aacFrameSize)
+	return append(tracks, at), nil
+}
+
+// ref is a sample of a track
+type ref struct {
+	track *mp4Track
+	index int
+}
+
+// layout order samples of tracks as they are in flv, and place them in mdat after ftyp and moov,
+// header is ftyp, moov and header of mdat
+func layout(tracks []*mp4Track) (header []byte, order []ref) {
+	var payload int64
+	for _, t := range tracks {
+		t.offsets = make([]int64, len(t.samples))
+		for _, s := range t.samples {
+			payload += int64(s.size)
+		}
+	}
+	mdat := builder{}
+	if payload+8 > math.MaxUint32 {
+		mdat.u32(1)
+		mdat.raw([]byte("mdat"))
+		mdat.u64(uint64(payload + 16))
+	} else {
+		mdat.u32(uint32(payload + 8))
+		mdat.raw([]byte("mdat"))
+	}
+	// size of moov doesn't change with offsets, only with their width
+	co64 := false
+	if int64(len(ftyp)+len(moov(tracks, false))+len(mdat))+payload > math.MaxUint32 {
+		co64 = true
+	}
+	off := int64(len(ftyp) + len(moov(tracks, co64)) + len(mdat))
+
+	next := make([]int, len(tracks))
+	for {
+		// sample earliest in flv among tracks
+		best := -1
+		for i, t := range tracks {
+			if next[i] < len(t.samples) && (best == -1 || t.samples[next[i]].off < tracks[best].samples[next[best]].off) {
+				best = i
+			}
+		}
+		if best == -1 {
+			break
+		}
+		t := tracks[best]
+		t.offsets[next[best]] = off
+		off += int64(t.samples[next[best]].size)
+		order = append(order, ref{track: t, index: next[best]})
+		next[best]++
+	}
+
+	header = append(header, ftyp...)
+	header = append(header, moov(tracks, co64)...)
+	header = append(header, mdat...)
+	return header, order
+}
+
+// writeMP4 write header and then data of samples copied from flv in order
+func writeMP4(src *os.File, name string, header []byte, order []ref) error {
+	of, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+	w := bufio.NewWriterSize(of, 1024*1024)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	var buf []byte
+	for _, r := range order {
+		s := r.track.samples[r.index]
+		if cap(buf) < int(s.size) {
+			buf = make([]byte, s.size)
+		}
+		buf = buf[:s.size]
+		if _, err := src.ReadAt(buf, s.off); err != nil {
+			return fmt.Errorf("read sample at offset %v error: %w", s.off, err)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return of.Close()
+}
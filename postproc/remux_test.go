@@ -0,0 +1,321 @@
+package postproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/verify"
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter write bits from the most significant one
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) bits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(v, n+1)
+}
+
+// testSPS build high profile sps of 1920x1080, coded as 1088 lines with 8 cropped
+func testSPS() []byte {
+	w := &bitWriter{}
+	w.bits(0x67, 8)
+	w.bits(100, 8) // profile
+	w.bits(0, 8)
+	w.bits(40, 8) // level
+	w.ue(0)       // sps id
+	w.ue(1)       // chroma 4:2:0
+	w.ue(0)
+	w.ue(0)
+	w.bits(0, 1)
+	w.bits(0, 1) // no scaling matrix
+	w.ue(0)
+	w.ue(0) // poc type
+	w.ue(0)
+	w.ue(4)
+	w.bits(0, 1)
+	w.ue(119) // 120 mbs
+	w.ue(67)  // 68 mbs
+	w.bits(1, 1)
+	w.bits(1, 1)
+	w.bits(1, 1) // cropping
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)
+	w.bits(0, 1) // no vui
+	w.bits(1, 1)
+	return w.data
+}
+
+func testAvcC() []byte {
+	sps, pps := testSPS(), []byte{0x68, 0xce, 0x3c, 0x80}
+	b := []byte{1, 100, 0, 40, 0xff, 0xe1, 0, byte(len(sps))}
+	b = append(b, sps...)
+	b = append(b, 1, 0, byte(len(pps)))
+	return append(b, pps...)
+}
+
+// flvWriter build flv of tags
+type flvWriter struct {
+	bytes.Buffer
+}
+
+func newFLV() *flvWriter {
+	w := &flvWriter{}
+	w.Write([]byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0})
+	return w
+}
+
+func (w *flvWriter) tag(typ byte, ts int, data ...[]byte) {
+	body := bytes.Join(data, nil)
+	size := len(body)
+	w.Write([]byte{typ, byte(size >> 16), byte(size >> 8), byte(size), byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24), 0, 0, 0})
+	w.Write(body)
+	binary.Write(w, binary.BigEndian, uint32(11+size))
+}
+
+func (w *flvWriter) video(ts int, key bool, cts int, payload []byte) {
+	frame := byte(0x27)
+	if key {
+		frame = 0x17
+	}
+	w.tag(flvTagVideo, ts, []byte{frame, 1, byte(cts >> 16), byte(cts >> 8), byte(cts)}, payload)
+}
+
+func (w *flvWriter) audio(ts int, payload []byte) {
+	w.tag(flvTagAudio, ts, []byte{0xaf, 1}, payload)
+}
+
+type frame struct {
+	ts      int
+	key     bool
+	cts     int
+	payload []byte
+}
+
+var (
+	testVideo = []frame{
+		{0, true, 40, []byte("video-0")},
+		{40, false, 120, []byte("video-1 p frame")},
+		{80, false, 0, []byte("video-2 b")},
+		{120, true, 40, []byte("video-3 key frame again")},
+		{160, false, 0, []byte("video-4")},
+	}
+	testAudio = []frame{
+		{0, true, 0, []byte("audio-0")},
+		{23, true, 0, []byte("audio-1 aac")},
+		{46, true, 0, []byte("audio-2")},
+		{70, true, 0, []byte("audio-3 aac frame")},
+		{93, true, 0, []byte("audio-4")},
+		{116, true, 0, []byte("audio-5")},
+		{139, true, 0, []byte("audio-6 last")},
+	}
+)
+
+// testFLV build flv of testVideo and testAudio in order of timestamps
+func testFLV(withAudio bool) []byte {
+	w := newFLV()
+	w.tag(flvTagScript, 0, []byte{2, 0, 10, 'o', 'n', 'M', 'e', 't', 'a', 'D', 'a', 't', 'a'})
+	w.tag(flvTagVideo, 0, []byte{0x17, 0, 0, 0, 0}, testAvcC())
+	if withAudio {
+		w.tag(flvTagAudio, 0, []byte{0xaf, 0, 0x12, 0x10})
+	}
+	a := 0
+	for _, v := range testVideo {
+		for withAudio && a < len(testAudio) && testAudio[a].ts <= v.ts {
+			w.audio(testAudio[a].ts, testAudio[a].payload)
+			a++
+		}
+		w.video(v.ts, v.key, v.cts, v.payload)
+	}
+	for withAudio && a < len(testAudio) {
+		w.audio(testAudio[a].ts, testAudio[a].payload)
+		a++
+	}
+	return w.Bytes()
+}
+
+// child return payload of the n-th box of typ in data
+func child(t *testing.T, data []byte, typ string, n int) []byte {
+	for len(data) >= 8 {
+		size := binary.BigEndian.Uint32(data)
+		require.True(t, size >= 8 && int(size) <= len(data), "invalid size of box %q", data[4:8])
+		if string(data[4:8]) == typ {
+			if n == 0 {
+				return data[8:size]
+			}
+			n--
+		}
+		data = data[size:]
+	}
+	t.Fatalf("box %v not found", typ)
+	return nil
+}
+
+// path return payload of box at path, all boxes are the first of their type
+func path(t *testing.T, data []byte, types ...string) []byte {
+	for _, typ := range types {
+		data = child(t, data, typ, 0)
+	}
+	return data
+}
+
+// table read entries of full box of tables as uint32 after version, flags and count
+func table(b []byte) []uint32 {
+	var v []uint32
+	for i := 8; i+4 <= len(b); i += 4 {
+		v = append(v, binary.BigEndian.Uint32(b[i:]))
+	}
+	return v
+}
+
+func remux(t *testing.T, flv []byte) ([]byte, string) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "a.flv"), filepath.Join(dir, "a.mp4")
+	require.Nil(t, os.WriteFile(in, flv, 0644))
+	require.Nil(t, RemuxFLV(in, out))
+	data, err := os.ReadFile(out)
+	require.Nil(t, err)
+	return data, out
+}
+
+func TestAvcSize(t *testing.T) {
+	w, h, err := avcSize(testAvcC())
+	require.Nil(t, err)
+	require.Equal(t, uint32(1920), w)
+	require.Equal(t, uint32(1080), h)
+}
+
+func TestAacConfig(t *testing.T) {
+	rate, channels, err := aacConfig([]byte{0x12, 0x10})
+	require.Nil(t, err)
+	require.Equal(t, uint32(44100), rate)
+	require.Equal(t, uint32(2), channels)
+
+	_, _, err = aacConfig([]byte{0x12})
+	require.NotNil(t, err)
+}
+
+func TestRemuxFLV(t *testing.T) {
+	data, out := remux(t, testFLV(true))
+
+	r, err := verify.File(out, &verify.Expect{Format: "mp4"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"ftyp", "moov", "mdat"}, r.Boxes)
+	require.Equal(t, 200*time.Millisecond, r.Duration)
+
+	moov := path(t, data, "moov")
+	video, audio := child(t, moov, "trak", 0), child(t, moov, "trak", 1)
+
+	tkhd := path(t, video, "tkhd")
+	require.Equal(t, uint32(1920<<16), binary.BigEndian.Uint32(tkhd[76:]))
+	require.Equal(t, uint32(1080<<16), binary.BigEndian.Uint32(tkhd[80:]))
+	require.Equal(t, uint32(1000), binary.BigEndian.Uint32(path(t, video, "mdia", "mdhd")[12:]))
+	require.Equal(t, uint32(44100), binary.BigEndian.Uint32(path(t, audio, "mdia", "mdhd")[12:]))
+
+	vstbl, astbl := path(t, video, "mdia", "minf", "stbl"), path(t, audio, "mdia", "minf", "stbl")
+	require.Equal(t, []uint32{5, 40}, table(child(t, vstbl, "stts", 0)))
+	require.Equal(t, []uint32{1, 40, 1, 120, 1, 0, 1, 40, 1, 0}, table(child(t, vstbl, "ctts", 0)))
+	require.Equal(t, []uint32{1, 4}, table(child(t, vstbl, "stss", 0)))
+	// aac frames are 1024 samples though flv timestamps are rounded to milliseconds
+	require.Equal(t, []uint32{7, 1024}, table(child(t, astbl, "stts", 0)))
+	require.NotContains(t, string(astbl), "stss")
+	require.Contains(t, string(path(t, video, "mdia", "minf", "stbl", "stsd")), "avcC")
+	require.Contains(t, string(path(t, audio, "mdia", "minf", "stbl", "stsd")), "esds")
+
+	for _, c := range []struct {
+		stbl   []byte
+		frames []frame
+	}{{vstbl, testVideo}, {astbl, testAudio}} {
+		offsets := table(child(t, c.stbl, "stco", 0))
+		sizes := table(child(t, c.stbl, "stsz", 0))[1:]
+		require.Len(t, offsets, len(c.frames))
+		for i, f := range c.frames {
+			require.Equal(t, uint32(len(f.payload)), sizes[i])
+			require.Equal(t, f.payload, data[offsets[i]:offsets[i]+sizes[i]], "sample %v", i)
+		}
+	}
+	// samples are interleaved as in flv
+	require.Contains(t, string(data), "audio-0video-0audio-1 aacvideo-1 p frame")
+}
+
+func TestRemuxFLVNoAudio(t *testing.T) {
+	data, out := remux(t, testFLV(false))
+	_, err := verify.File(out, &verify.Expect{Format: "mp4"})
+	require.Nil(t, err)
+	moov := path(t, data, "moov")
+	child(t, moov, "trak", 0)
+	require.Equal(t, -1, bytes.Index(moov, []byte("soun")))
+}
+
+func TestRemuxFLVDelay(t *testing.T) {
+	w := newFLV()
+	w.tag(flvTagVideo, 0, []byte{0x17, 0, 0, 0, 0}, testAvcC())
+	for i := 0; i < 3; i++ {
+		w.video(500+i*40, i == 0, 0, []byte(fmt.Sprintf("video-%v", i)))
+	}
+	data, _ := remux(t, w.Bytes())
+	elst := path(t, data, "moov", "trak", "edts", "elst")
+	// empty edit of 500ms and then the whole media
+	require.Equal(t, []uint32{500, 0xffffffff, 0x10000, 120, 0, 0x10000}, table(elst))
+	require.Equal(t, []uint32{3, 40}, table(path(t, data, "moov", "trak", "mdia", "minf", "stbl", "stts")))
+}
+
+func TestRemuxFLVError(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"hevc": func() []byte {
+			w := newFLV()
+			w.tag(flvTagVideo, 0, []byte{0x1c, 0, 0, 0, 0}, testAvcC())
+			return w.Bytes()
+		}(),
+		"mp3": func() []byte {
+			w := newFLV()
+			w.tag(flvTagVideo, 0, []byte{0x17, 0, 0, 0, 0}, testAvcC())
+			w.tag(flvTagAudio, 0, []byte{0x2f, 0})
+			return w.Bytes()
+		}(),
+	} {
+		in, out := filepath.Join(dir, name+".flv"), filepath.Join(dir, name+".mp4")
+		require.Nil(t, os.WriteFile(in, data, 0644))
+		err := RemuxFLV(in, out)
+		require.True(t, errors.Is(err, ErrUnsupported), "%v: %v", name, err)
+		_, err = os.Stat(out)
+		require.True(t, os.IsNotExist(err))
+	}
+
+	in, out := filepath.Join(dir, "truncated.flv"), filepath.Join(dir, "truncated.mp4")
+	flv := testFLV(true)
+	require.Nil(t, os.WriteFile(in, flv[:len(flv)-10], 0644))
+	require.NotNil(t, RemuxFLV(in, out))
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	for _, e := range entries {
+		require.NotEqual(t, ".mp4", filepath.Ext(e.Name()))
+	}
+}
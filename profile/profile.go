@@ -0,0 +1,150 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rammiah/bili-downloader/download/cookie"
+)
+
+const (
+	// Default profile keeps cookies and settings in the config directory itself,
+	// so files written by old versions keep working
+	Default = "default"
+
+	profilesDir  = "profiles"
+	currentFile  = "profile" // name of the profile selected by `profile use`
+	settingsFile = "config.toml"
+)
+
+var (
+	ErrNotFound = errors.New("profile not found")
+	ErrExists   = errors.New("profile already exists")
+
+	nameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// root return directory profiles are saved in, var for tests
+var root = cookie.ConfigDir
+
+// CheckName check if name can be used as profile name
+func CheckName(name string) error {
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, only letters, digits, _ and - allowed", name)
+	}
+	return nil
+}
+
+// Dir return directory of profile
+func Dir(name string) string {
+	if name == Default {
+		return root()
+	}
+	return filepath.Join(root(), profilesDir, name)
+}
+
+// Exists report if profile exists, default profile always exists
+func Exists(name string) bool {
+	if name == Default {
+		return true
+	}
+	st, err := os.Stat(Dir(name))
+	return err == nil && st.IsDir()
+}
+
+// List return names of all profiles, default profile first
+func List() ([]string, error) {
+	names := []string{Default}
+	ents, err := os.ReadDir(filepath.Join(root(), profilesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, err
+	}
+	var others []string
+	for _, ent := range ents {
+		if ent.IsDir() && CheckName(ent.Name()) == nil && ent.Name() != Default {
+			others = append(others, ent.Name())
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...), nil
+}
+
+// Add create a new empty profile
+func Add(name string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	if Exists(name) {
+		return ErrExists
+	}
+	return os.MkdirAll(Dir(name), 0700)
+}
+
+// Remove delete profile with its cookies and settings, switch back to default profile if it's in use
+func Remove(name string) error {
+	if name == Default {
+		return errors.New("default profile can't be removed")
+	}
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	if !Exists(name) {
+		return ErrNotFound
+	}
+	if Current() == name {
+		if err := SetCurrent(Default); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(Dir(name))
+}
+
+// Current return name of profile selected by SetCurrent
+func Current() string {
+	buf, err := os.ReadFile(filepath.Join(root(), currentFile))
+	if err != nil {
+		return Default
+	}
+	name := strings.TrimSpace(string(buf))
+	if CheckName(name) != nil || !Exists(name) {
+		return Default
+	}
+	return name
+}
+
+// SetCurrent select profile used when no profile given
+func SetCurrent(name string) error {
+	if !Exists(name) {
+		return ErrNotFound
+	}
+	file := filepath.Join(root(), currentFile)
+	if name == Default {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(file, []byte(name+"\n"), 0644)
+}
+
+// Activate load cookies of profile into the shared cookie jar, cookies are saved back to profile
+func Activate(name string) error {
+	if !Exists(name) {
+		return fmt.Errorf("%w: %v", ErrNotFound, name)
+	}
+	return cookie.Use(Dir(name))
+}
+
+// ConfigFile return config file of profile, it overrides the global config file.
+// config file of default profile is the global one
+func ConfigFile(name string) string {
+	return filepath.Join(Dir(name), settingsFile)
+}
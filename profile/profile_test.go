@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rammiah/bili-downloader/download/cookie"
+	"github.com/stretchr/testify/require"
+)
+
+func useTempRoot(t *testing.T) string {
+	dir := t.TempDir()
+	old := root
+	root = func() string { return dir }
+	t.Cleanup(func() { root = old })
+	return dir
+}
+
+func TestProfiles(t *testing.T) {
+	dir := useTempRoot(t)
+
+	names, err := List()
+	require.Nil(t, err)
+	require.Equal(t, []string{Default}, names)
+	require.Equal(t, Default, Current())
+	require.Equal(t, dir, Dir(Default))
+
+	require.Nil(t, Add("premium"))
+	require.Nil(t, Add("daily"))
+	require.Equal(t, ErrExists, Add("daily"))
+	require.NotNil(t, Add("../evil"))
+	require.NotNil(t, Add(""))
+
+	names, err = List()
+	require.Nil(t, err)
+	require.Equal(t, []string{Default, "daily", "premium"}, names)
+
+	require.Nil(t, SetCurrent("premium"))
+	require.Equal(t, "premium", Current())
+	require.Equal(t, ErrNotFound, SetCurrent("nobody"))
+
+	require.Nil(t, Remove("premium"))
+	require.Equal(t, Default, Current())
+	require.False(t, Exists("premium"))
+	require.Equal(t, ErrNotFound, Remove("premium"))
+	require.NotNil(t, Remove(Default))
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := useTempRoot(t)
+	require.Equal(t, filepath.Join(dir, "config.toml"), ConfigFile(Default))
+	require.Equal(t, filepath.Join(dir, "profiles", "premium", "config.toml"), ConfigFile("premium"))
+}
+
+func TestActivate(t *testing.T) {
+	useTempRoot(t)
+	require.Nil(t, Add("premium"))
+	require.Nil(t, Add("daily"))
+
+	require.Nil(t, Activate("premium"))
+	cookie.SetCookies(cookie.ParseCookies("SESSDATA=premium"))
+	cookie.SaveCookies()
+
+	require.Nil(t, Activate("daily"))
+	require.Empty(t, cookie.GetCookieJar().All())
+
+	require.Nil(t, Activate("premium"))
+	cks := cookie.GetCookieJar().All()
+	require.Len(t, cks, 1)
+	require.Equal(t, "premium", cks[0].Value)
+	require.Equal(t, Dir("premium"), cookie.StoreDir())
+
+	require.NotNil(t, Activate("nobody"))
+}
@@ -0,0 +1,159 @@
+package progress
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rammiah/bili-downloader/download"
+)
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// SchemaVersion is bumped when fields of json events are removed or changed, adding fields keeps it
+const SchemaVersion = 1
+
+// Record is a line written by JSONWriter, fields not related to event are omitted
+type Record struct {
+	Version    int       `json:"v"`
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Job        int       `json:"job"` // 1 based, in order jobs are created
+	ID         string    `json:"id,omitempty"`
+	Page       int64     `json:"page,omitempty"`
+	File       string    `json:"file,omitempty"`
+	Quality    string    `json:"quality,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	Fragments  int       `json:"fragments,omitempty"`
+	Fragment   *int      `json:"fragment,omitempty"` // 0 based
+	Bytes      int64     `json:"bytes,omitempty"`
+	ElapsedMs  int64     `json:"elapsed_ms,omitempty"`
+	Downloaded int64     `json:"downloaded"`
+	Percent    float64   `json:"percent"`
+	Speed      int64     `json:"speed"`                 // bytes per second
+	ETASeconds *int64    `json:"eta_seconds,omitempty"` // missing if speed is unknown
+	Attempt    int       `json:"attempt,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// JSONWriter write download events of jobs as json lines
+type JSONWriter struct {
+	mu   sync.Mutex
+	enc  *jsoniter.Encoder
+	jobs int
+	err  error
+}
+
+// NewJSONWriter create writer write to w
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONWriter{enc: enc}
+}
+
+// Job is a file downloaded, fields can be set until the first event is handled
+type Job struct {
+	ID      string
+	Page    int64
+	File    string
+	Quality string
+
+	w     *JSONWriter
+	no    int
+	size  int64
+	meter speedMeter
+}
+
+// Job create a job of page of video id
+func (w *JSONWriter) Job(id string, page int64) *Job {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.jobs++
+	return &Job{ID: id, Page: page, w: w, no: w.jobs}
+}
+
+// Handle write event of downloader, it's the event handler of VideoDownloader
+func (j *Job) Handle(e *download.Event) {
+	j.w.mu.Lock()
+	defer j.w.mu.Unlock()
+
+	r := j.record(string(e.Type), e.Time)
+	j.size = e.Total
+	r.Downloaded = e.Done
+	if e.Total > 0 {
+		r.Percent = math.Round(float64(e.Done)*1000/float64(e.Total)) / 10
+	}
+	switch e.Type {
+	case download.EventStart:
+		r.File, r.Quality, r.Size = j.File, j.Quality, e.Total
+		r.Fragments = e.Fragments
+		j.meter.start(e.Time, e.Done)
+	case download.EventFragmentDone:
+		r.Fragment = &e.Fragment
+		r.Bytes = e.Bytes
+		r.ElapsedMs = e.Elapsed.Milliseconds()
+	case download.EventRetry:
+		r.Fragment = &e.Fragment
+		r.Attempt = e.Attempt
+	case download.EventComplete:
+		r.File = j.File
+		r.ElapsedMs = e.Elapsed.Milliseconds()
+		if e.Elapsed > 0 {
+			// average speed of the whole download
+			j.meter.speed = float64(e.Done) / e.Elapsed.Seconds()
+		}
+	}
+	if e.Err != nil {
+		r.Error = e.Err.Error()
+	}
+	if e.Type != download.EventComplete {
+		j.meter.update(e.Time, e.Done)
+	}
+	j.fillSpeed(r)
+	j.w.write(r)
+}
+
+// Fail write error of job happened out of downloader, like getting playurl
+func (j *Job) Fail(err error) {
+	j.w.mu.Lock()
+	defer j.w.mu.Unlock()
+	r := j.record(string(download.EventError), time.Now())
+	r.Error = err.Error()
+	j.w.write(r)
+}
+
+func (j *Job) record(event string, t time.Time) *Record {
+	return &Record{
+		Version: SchemaVersion,
+		Event:   event,
+		Time:    t,
+		Job:     j.no,
+		ID:      j.ID,
+		Page:    j.Page,
+	}
+}
+
+func (j *Job) fillSpeed(r *Record) {
+	r.Speed = int64(j.meter.speed)
+	if j.size > 0 {
+		if eta, ok := j.meter.eta(j.size - r.Downloaded); ok {
+			r.ETASeconds = &eta
+		}
+	}
+}
+
+func (w *JSONWriter) write(r *Record) {
+	if w.err != nil {
+		return
+	}
+	if err := w.enc.Encode(r); err != nil {
+		// stop writing, reader is probably gone
+		log.Errorf("write progress event error: %v", err)
+		w.err = err
+	}
+}
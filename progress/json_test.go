@@ -0,0 +1,91 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/stretchr/testify/require"
+)
+
+func readRecords(t *testing.T, buf *bytes.Buffer) []*Record {
+	var records []*Record
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		r := &Record{}
+		require.Nil(t, json.Unmarshal(sc.Bytes(), r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestJSONWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewJSONWriter(buf)
+	failed := w.Job("BV1pP4y1b7iP", 1)
+	job := w.Job("BV1pP4y1b7iP", 2)
+	job.File, job.Quality = "a.flv", "1080P"
+
+	t0 := time.Date(2021, 11, 8, 0, 0, 0, 0, time.UTC)
+	failed.Fail(errors.New("code not 0: -404"))
+	for _, e := range []*download.Event{
+		{Type: download.EventStart, Time: t0, Fragments: 4, Total: 4000},
+		{Type: download.EventFragmentDone, Time: t0.Add(time.Second), Fragment: 0, Bytes: 1000, Elapsed: time.Second, Done: 1000, Total: 4000},
+		{Type: download.EventRetry, Time: t0.Add(2 * time.Second), Fragment: 1, Attempt: 1, Err: errors.New("timeout"), Done: 1000, Total: 4000},
+		{Type: download.EventProgress, Time: t0.Add(3 * time.Second), Done: 3000, Total: 4000},
+		{Type: download.EventComplete, Time: t0.Add(4 * time.Second), Elapsed: 4 * time.Second, Done: 4000, Total: 4000},
+	} {
+		job.Handle(e)
+	}
+
+	records := readRecords(t, buf)
+	require.Len(t, records, 6)
+	require.Equal(t, &Record{Version: 1, Event: "error", Time: records[0].Time, Job: 1, ID: "BV1pP4y1b7iP", Page: 1,
+		Error: "code not 0: -404"}, records[0])
+
+	start := records[1]
+	require.Equal(t, "start", start.Event)
+	require.Equal(t, 2, start.Job)
+	require.Equal(t, "a.flv", start.File)
+	require.Equal(t, "1080P", start.Quality)
+	require.Equal(t, int64(4000), start.Size)
+	require.Equal(t, 4, start.Fragments)
+	require.Nil(t, start.ETASeconds)
+
+	frag := records[2]
+	require.Equal(t, 0, *frag.Fragment)
+	require.Equal(t, int64(1000), frag.ElapsedMs)
+	require.Equal(t, 25.0, frag.Percent)
+	require.Equal(t, int64(1000), frag.Speed)
+	require.Equal(t, int64(3), *frag.ETASeconds)
+
+	retry := records[3]
+	require.Equal(t, 1, *retry.Fragment)
+	require.Equal(t, 1, retry.Attempt)
+	require.Equal(t, "timeout", retry.Error)
+	// no bytes in the last second
+	require.Equal(t, int64(700), retry.Speed)
+
+	// 0.3*2000 + 0.7*700
+	require.Equal(t, int64(1090), records[4].Speed)
+
+	done := records[5]
+	require.Equal(t, "complete", done.Event)
+	require.Equal(t, 100.0, done.Percent)
+	require.Equal(t, int64(1000), done.Speed)
+	require.Equal(t, int64(0), *done.ETASeconds)
+}
+
+func TestJSONLine(t *testing.T) {
+	buf := &bytes.Buffer{}
+	job := NewJSONWriter(buf).Job("ep1", 1)
+	job.Handle(&download.Event{
+		Type: download.EventFragmentDone,
+		Time: time.Date(2021, 11, 8, 0, 0, 0, 0, time.UTC),
+	})
+	require.Equal(t, `{"v":1,"event":"fragment_done","time":"2021-11-08T00:00:00Z","job":1,"id":"ep1","page":1,`+
+		`"fragment":0,"downloaded":0,"percent":0,"speed":0}`+"\n", buf.String())
+}
@@ -0,0 +1,79 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+)
+
+// plainInterval is interval progress lines are printed
+const plainInterval = 10 * time.Second
+
+// Plain print a line when a file starts, every plainInterval while downloading and when it finishes,
+// it's used when output is not a terminal
+type Plain struct {
+	mu       sync.Mutex
+	out      io.Writer
+	interval time.Duration
+}
+
+// NewPlain create reporter write lines to w
+func NewPlain(w io.Writer) *Plain {
+	return &Plain{out: w, interval: plainInterval}
+}
+
+type plainTracker struct {
+	*bar
+	p    *Plain
+	stop chan struct{}
+	exit chan struct{}
+}
+
+// Start print start line of file and report it periodically
+func (p *Plain) Start(name string, total int64) download.ProgressTracker {
+	tr := &plainTracker{
+		bar:  newBar(name, total, time.Now()),
+		p:    p,
+		stop: make(chan struct{}),
+		exit: make(chan struct{}),
+	}
+	p.println(fmt.Sprintf("%v: start, %v", tr.name, consts.Byte(total)))
+	go tr.report()
+	return tr
+}
+
+func (tr *plainTracker) report() {
+	defer close(tr.exit)
+	ticker := time.NewTicker(tr.p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			tr.p.mu.Lock()
+			tr.meter.update(now, atomic.LoadInt64(&tr.done))
+			tr.p.mu.Unlock()
+			tr.p.println(tr.name + ": " + tr.stats(now))
+		case <-tr.stop:
+			return
+		}
+	}
+}
+
+// Finish print result of file
+func (tr *plainTracker) Finish(err error) {
+	close(tr.stop)
+	<-tr.exit
+	tr.finished, tr.err = true, err
+	tr.p.println(tr.name + ": " + tr.stats(time.Now()))
+}
+
+func (p *Plain) println(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.out, line)
+}
@@ -0,0 +1,55 @@
+package progress
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// speedAlpha is weight of the newest sample in smoothed speed
+	speedAlpha = 0.3
+	// minSample is the shortest interval a speed sample is taken from
+	minSample = 200 * time.Millisecond
+)
+
+// speedMeter smooth download speed with exponential moving average
+type speedMeter struct {
+	lastTime time.Time
+	lastDone int64
+	speed    float64 // bytes per second, 0 if unknown
+}
+
+// start take bytes done at t as the first sample
+func (m *speedMeter) start(t time.Time, done int64) {
+	m.lastTime, m.lastDone = t, done
+}
+
+// update take sample of bytes done at t, samples closer than minSample to the last one are dropped
+func (m *speedMeter) update(t time.Time, done int64) {
+	dt := t.Sub(m.lastTime)
+	if m.lastTime.IsZero() || dt < minSample {
+		return
+	}
+	cur := float64(done-m.lastDone) / dt.Seconds()
+	if cur < 0 {
+		// bytes of failed fragment are taken back
+		cur = 0
+	}
+	if m.speed == 0 {
+		m.speed = cur
+	} else {
+		m.speed = speedAlpha*cur + (1-speedAlpha)*m.speed
+	}
+	m.lastTime, m.lastDone = t, done
+}
+
+// eta return seconds to download left bytes, false if speed is unknown
+func (m *speedMeter) eta(left int64) (int64, bool) {
+	if m.speed <= 0 {
+		return 0, false
+	}
+	if left < 0 {
+		left = 0
+	}
+	return int64(math.Ceil(float64(left) / m.speed)), true
+}
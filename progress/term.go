@@ -0,0 +1,83 @@
+package progress
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// defaultWidth is used when width of terminal is unknown
+const defaultWidth = 80
+
+// IsTerminal report if f is a terminal
+func IsTerminal(f *os.File) bool {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t)))
+	return errno == 0
+}
+
+// termWidth return columns of terminal f, $COLUMNS or defaultWidth if unknown
+func termWidth(f *os.File) int {
+	var ws struct {
+		Row, Col, X, Y uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno == 0 && ws.Col > 0 {
+		return int(ws.Col)
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultWidth
+}
+
+// runeWidth return columns r takes in terminal, wide east asian characters take 2
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115f, // hangul jamo
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f, // cjk radicals to yi
+		r >= 0xac00 && r <= 0xd7a3,                // hangul syllables
+		r >= 0xf900 && r <= 0xfaff,                // cjk compatibility ideographs
+		r >= 0xfe30 && r <= 0xfe4f,                // cjk compatibility forms
+		r >= 0xff00 && r <= 0xff60,                // fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f, // emoji
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// textWidth return columns s takes in terminal
+func textWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// truncate cut s to at most width columns, ... is appended if cut
+func truncate(s string, width int) string {
+	if textWidth(s) <= width {
+		return s
+	}
+	if width <= 3 {
+		return ""
+	}
+	w, i := 0, 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if w+runeWidth(r) > width-3 {
+			break
+		}
+		w += runeWidth(r)
+		i += size
+	}
+	return s[:i] + "..."
+}
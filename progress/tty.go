@@ -0,0 +1,233 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+)
+
+// refreshInterval is interval bars are redrawn
+const refreshInterval = 100 * time.Millisecond
+
+// NewReporter return TTY reporter if f is a terminal, Plain reporter otherwise
+func NewReporter(f *os.File) download.ProgressReporter {
+	if IsTerminal(f) {
+		return NewTTY(f)
+	}
+	return NewPlain(f)
+}
+
+// bar is progress of a file
+type bar struct {
+	name  string
+	total int64
+	done  int64
+	start time.Time
+	meter speedMeter // guarded by mutex of reporter
+
+	finished bool
+	err      error
+}
+
+func newBar(name string, total int64, now time.Time) *bar {
+	b := &bar{name: filepath.Base(name), total: total, start: now}
+	b.meter.start(now, 0)
+	return b
+}
+
+func (b *bar) Add(n int64) {
+	atomic.AddInt64(&b.done, n)
+}
+
+func (b *bar) percent(done int64) int {
+	if b.total <= 0 || done >= b.total {
+		return 100
+	}
+	return int(done * 100 / b.total)
+}
+
+// stats render like 45% 12.30 MB/27.10 MB 2.10 MB/s ETA 00:07, or the result if finished
+func (b *bar) stats(now time.Time) string {
+	done := atomic.LoadInt64(&b.done)
+	if b.finished {
+		if b.err != nil {
+			return "failed: " + b.err.Error()
+		}
+		elapsed := now.Sub(b.start)
+		speed := consts.Byte(0)
+		if elapsed > 0 {
+			speed = consts.Byte(float64(done) / elapsed.Seconds())
+		}
+		return fmt.Sprintf("%v in %v, %v/s", consts.Byte(b.total), clock(int64(elapsed.Seconds())), speed)
+	}
+	eta := "--:--"
+	if sec, ok := b.meter.eta(b.total - done); ok {
+		eta = clock(sec)
+	}
+	return fmt.Sprintf("%3d%% %v/%v %v/s ETA %v", b.percent(done), consts.Byte(done), consts.Byte(b.total),
+		consts.Byte(b.meter.speed), eta)
+}
+
+// clock format seconds like 1:02:03 or 02:03
+func clock(sec int64) string {
+	if sec >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+	}
+	return fmt.Sprintf("%02d:%02d", sec/60, sec%60)
+}
+
+// line render bar in width columns: name [#####     ] stats,
+// the bar is dropped if there is no room and stats are cut at last
+func (b *bar) line(width int, now time.Time) string {
+	// keep off the last column, or terminal may wrap
+	width--
+	stats := b.stats(now)
+	avail := width - textWidth(stats) - 1
+	if avail < 8 {
+		return truncate(b.name+" "+stats, width)
+	}
+
+	nameWidth := textWidth(b.name)
+	if b.finished {
+		return truncate(b.name, avail) + " " + stats
+	}
+	if nameWidth > avail/2 {
+		nameWidth = avail / 2
+	}
+	barWidth := avail - nameWidth - 3
+	if barWidth < 10 {
+		return truncate(b.name, avail) + " " + stats
+	}
+	name := truncate(b.name, nameWidth)
+	name += strings.Repeat(" ", nameWidth-textWidth(name))
+	fill := barWidth * b.percent(atomic.LoadInt64(&b.done)) / 100
+	return name + " [" + strings.Repeat("#", fill) + strings.Repeat(" ", barWidth-fill) + "] " + stats
+}
+
+// TTY draw a bar for every file being downloaded at the bottom of terminal,
+// finished files and log lines are printed above the bars
+type TTY struct {
+	mu      sync.Mutex
+	out     io.Writer
+	width   func() int
+	bars    []*bar
+	drawn   int  // lines of bars on screen
+	running bool // bars are being refreshed
+}
+
+// NewTTY create reporter draw on terminal f
+func NewTTY(f *os.File) *TTY {
+	return &TTY{
+		out:   f,
+		width: func() int { return termWidth(f) },
+	}
+}
+
+type ttyTracker struct {
+	*bar
+	t *TTY
+}
+
+// Start add bar of file
+func (t *TTY) Start(name string, total int64) download.ProgressTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := newBar(name, total, time.Now())
+	t.bars = append(t.bars, b)
+	if !t.running {
+		t.running = true
+		go t.refresh()
+	}
+	t.clear()
+	t.draw(time.Now())
+	return &ttyTracker{bar: b, t: t}
+}
+
+// Finish remove bar of file and print the result above running bars
+func (tr *ttyTracker) Finish(err error) {
+	t := tr.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr.finished, tr.err = true, err
+	for i, b := range t.bars {
+		if b == tr.bar {
+			t.bars = append(t.bars[:i], t.bars[i+1:]...)
+			break
+		}
+	}
+	now := time.Now()
+	t.clear()
+	fmt.Fprintln(t.out, tr.line(t.width(), now))
+	t.draw(now)
+}
+
+// LogWriter return writer of log lines, bars are cleared before lines are written to w and drawn again after
+func (t *TTY) LogWriter(w io.Writer) io.Writer {
+	return &ttyLog{t: t, w: w}
+}
+
+type ttyLog struct {
+	t *TTY
+	w io.Writer
+}
+
+func (l *ttyLog) Write(p []byte) (int, error) {
+	l.t.mu.Lock()
+	defer l.t.mu.Unlock()
+	l.t.clear()
+	n, err := l.w.Write(p)
+	l.t.draw(time.Now())
+	return n, err
+}
+
+// refresh redraw bars until all of them are finished
+func (t *TTY) refresh() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		t.mu.Lock()
+		if len(t.bars) == 0 {
+			t.running = false
+			t.mu.Unlock()
+			return
+		}
+		for _, b := range t.bars {
+			b.meter.update(now, atomic.LoadInt64(&b.done))
+		}
+		t.clear()
+		t.draw(now)
+		t.mu.Unlock()
+	}
+}
+
+// clear erase bars on screen, cursor is left at the first line of bars
+func (t *TTY) clear() {
+	if t.drawn == 0 {
+		return
+	}
+	fmt.Fprintf(t.out, "\x1b[%dA\r\x1b[J", t.drawn)
+	t.drawn = 0
+}
+
+// draw bars from cursor, it's called after clear
+func (t *TTY) draw(now time.Time) {
+	if len(t.bars) == 0 {
+		return
+	}
+	width := t.width()
+	var sb strings.Builder
+	for _, b := range t.bars {
+		sb.WriteString(b.line(width, now))
+		sb.WriteString("\n")
+	}
+	io.WriteString(t.out, sb.String())
+	t.drawn = len(t.bars)
+}
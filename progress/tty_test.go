@@ -0,0 +1,88 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, 9, textWidth("测试a.flv"))
+	require.Equal(t, "测试a.flv", truncate("测试a.flv", 9))
+	require.Equal(t, "测试a...", truncate("测试a.flv", 8))
+	require.Equal(t, "测...", truncate("测试a.flv", 6))
+	require.Equal(t, "测...", truncate("测试a.flv", 5))
+	require.Equal(t, "", truncate("测试a.flv", 3))
+	require.Equal(t, "01:05", clock(65))
+	require.Equal(t, "1:01:05", clock(3665))
+}
+
+func TestBarLine(t *testing.T) {
+	t0 := time.Date(2021, 11, 8, 0, 0, 0, 0, time.UTC)
+	b := newBar("dir/测试视频.flv", 10*int64(consts.MB), t0)
+	b.Add(int64(consts.MB))
+	b.meter.update(t0.Add(time.Second), b.done)
+
+	line := b.line(80, t0.Add(time.Second))
+	require.Equal(t, "测试视频.flv [##                    ]  10% 1.00 MB/10.00 MB 1.00 MB/s ETA 00:09", line)
+	require.Equal(t, 79, textWidth(line))
+
+	// narrow terminal drops the bar, then cuts stats
+	require.Equal(t, "测试视频.flv  10% 1.00 MB/10.00 MB 1.00 MB/s ETA 00:09", b.line(60, t0.Add(time.Second)))
+	require.Equal(t, "测试视频.flv  10% 1.00 MB/10.00 MB...", b.line(38, t0.Add(time.Second)))
+
+	b.Add(9 * int64(consts.MB))
+	b.finished = true
+	require.Equal(t, "测试视频.flv 10.00 MB in 00:02, 5.00 MB/s", b.line(80, t0.Add(2*time.Second)))
+	b.err = errors.New("timeout")
+	require.Equal(t, "测试视频.flv failed: timeout", b.line(80, t0))
+}
+
+func TestTTY(t *testing.T) {
+	out, logs := &bytes.Buffer{}, &bytes.Buffer{}
+	tty := &TTY{out: out, width: func() int { return 60 }}
+
+	a := tty.Start("a.flv", 100)
+	tty.Start("b.flv", 100)
+	require.Equal(t, 2, tty.drawn)
+	out.Reset()
+
+	// bars are cleared before log and drawn after
+	w := tty.LogWriter(logs)
+	w.Write([]byte("log line\n"))
+	require.Equal(t, "log line\n", logs.String())
+	require.True(t, strings.HasPrefix(out.String(), "\x1b[2A\r\x1b[J"))
+	require.Equal(t, 2, strings.Count(out.String(), "\n"))
+
+	// finished bar is printed once above the running one
+	out.Reset()
+	a.Add(100)
+	a.Finish(nil)
+	lines := strings.Split(strings.TrimPrefix(out.String(), "\x1b[2A\r\x1b[J"), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[0], "a.flv 100 B in 00:00"))
+	require.True(t, strings.HasPrefix(lines[1], "b.flv"))
+	require.Equal(t, 1, tty.drawn)
+}
+
+func TestPlain(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewPlain(out)
+	p.interval = 10 * time.Millisecond
+	tr := p.Start("dir/a.flv", 2048)
+	tr.Add(1024)
+	time.Sleep(35 * time.Millisecond)
+	tr.Finish(errors.New("timeout"))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, "a.flv: start, 2.00 KB", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "a.flv:  50% 1.00 KB/2.00 KB "))
+	require.Equal(t, "a.flv: failed: timeout", lines[len(lines)-1])
+}
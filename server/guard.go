@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenCookie keeps token of web ui after it's opened by /?token=
+const tokenCookie = "bilidown_token"
+
+// guard protect api and metrics of h from other sites and hosts:
+// token if set is required, as bearer token or cookie set by opening /?token=,
+// without token Host should be an ip address or localhost so dns rebinding can't reach api,
+// and requests changing state should be json from the same origin, which pages of other sites
+// can't send without a preflight
+func guard(h http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.URL.Path == "/" && r.URL.Query().Get("token") != "" {
+			if !equalToken(r.URL.Query().Get("token"), token) {
+				writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/metrics" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		switch {
+		case token != "":
+			if !authorized(r, token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="bilidown"`)
+				writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+				return
+			}
+		case !localHost(r.Host):
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %v not allowed without token", r.Host))
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+					writeError(w, http.StatusForbidden, fmt.Errorf("origin %v not allowed", origin))
+					return
+				}
+			}
+			if typ, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); typ != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errors.New("content type should be application/json"))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authorized check bearer token or cookie of request
+func authorized(r *http.Request, token string) bool {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return equalToken(strings.TrimPrefix(auth, "Bearer "), token)
+	}
+	c, err := r.Cookie(tokenCookie)
+	return err == nil && equalToken(c.Value, token)
+}
+
+func equalToken(got, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// localHost report if host of request is an ip address or localhost, names other sites could resolve
+// to this server are not
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	return strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil
+}
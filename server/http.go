@@ -0,0 +1,200 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/metrics"
+)
+
+// heartbeatInterval is interval comments are sent to keep event streams alive
+const heartbeatInterval = 30 * time.Second
+
+// EnqueueRequest is body of POST /api/jobs
+type EnqueueRequest struct {
+	Targets []string `json:"targets"`           // ids or urls of video
+	Pages   string   `json:"pages,omitempty"`   // pages like 1,3-5, all if empty
+	Quality string   `json:"quality,omitempty"` // best, qn number or name like 1080P, quality of config if empty
+}
+
+// JobList is response of job lists
+type JobList struct {
+	Jobs []*Job `json:"jobs"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler create handler of REST api of m, token is required by api if not empty:
+//
+//	GET  /api/jobs                list jobs
+//	POST /api/jobs                enqueue targets, body is EnqueueRequest
+//	GET  /api/jobs/{id}           get job
+//	POST /api/jobs/{id}/pause     pause job
+//	POST /api/jobs/{id}/resume    resume paused or failed job
+//	POST /api/jobs/{id}/cancel    cancel job
+//	GET  /api/events              server sent events of jobs
+//	GET  /api/info?target=        pages of video and qualities of its first page
+//	GET  /metrics                 prometheus metrics
+//
+// other paths are files of web ui, which keeps token in a cookie after opened by /?token=.
+// Requests changing state should be application/json from the same origin.
+func NewHandler(m *Manager, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", webHandler())
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, &JobList{Jobs: m.Jobs()})
+		case http.MethodPost:
+			var req EnqueueRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("parse body error: %w", err))
+				return
+			}
+			jobs, err := m.Enqueue(req.Targets, req.Pages, req.Quality)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, &JobList{Jobs: jobs})
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+	})
+	mux.HandleFunc("/api/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || len(parts) > 2 {
+			writeError(w, http.StatusNotFound, ErrNotFound)
+			return
+		}
+		if len(parts) == 1 {
+			if r.Method != http.MethodGet {
+				methodNotAllowed(w, http.MethodGet)
+				return
+			}
+			job, err := m.Job(id)
+			writeResult(w, job, err)
+			return
+		}
+
+		ops := map[string]func(int64) (*Job, error){
+			"pause":  m.Pause,
+			"resume": m.Resume,
+			"cancel": m.Cancel,
+		}
+		op, ok := ops[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown operation %q", parts[1]))
+			return
+		}
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		job, err := op(id)
+		writeResult(w, job, err)
+	})
+	mux.HandleFunc("/api/info", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		id, err := download.ParseId(r.URL.Query().Get("target"))
+		if err == nil && id == "" {
+			err = errors.New("empty target")
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		info, err := m.Info(id)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	})
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		serveEvents(w, r, m)
+	})
+	return guard(mux, token)
+}
+
+// serveEvents stream events of m until client leaves, event name is type of event and data is the job
+func serveEvents(w http.ResponseWriter, r *http.Request, m *Manager) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	events, cancel := m.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e := <-events:
+			data, err := json.Marshal(e.Job)
+			if err != nil {
+				log.Errorf("marshal event error: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeResult(w http.ResponseWriter, job *Job, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrState):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("write response error: %v", err)
+	}
+}
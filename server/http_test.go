@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rammiah/bili-downloader/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func doRequest(t *testing.T, method, url, body string, v interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.Nil(t, err)
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(v))
+	return resp.StatusCode
+}
+
+func TestHandler(t *testing.T) {
+	runner := newFakeRunner(t, 1)
+	runner.block = true
+	m, err := NewManager(runner, NewStore(filepath.Join(t.TempDir(), "jobs.json")), 1)
+	require.Nil(t, err)
+	m.Start()
+	defer m.Close()
+	srv := httptest.NewServer(NewHandler(m, ""))
+	defer srv.Close()
+
+	// subscribe before jobs are added
+	resp, err := http.Get(srv.URL + "/api/events")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var errResp errorResponse
+	require.Equal(t, http.StatusBadRequest, doRequest(t, http.MethodPost, srv.URL+"/api/jobs", `{"targets": ["xx"]}`, &errResp))
+	require.Contains(t, errResp.Error, "unrecognized video id")
+
+	var list JobList
+	require.Equal(t, http.StatusCreated, doRequest(t, http.MethodPost, srv.URL+"/api/jobs",
+		`{"targets": ["BV1pP4y1b7iP", "ep123"], "pages": "1"}`, &list))
+	require.Len(t, list.Jobs, 2)
+	require.Equal(t, "ep123", list.Jobs[1].VideoID)
+
+	require.Equal(t, http.StatusOK, doRequest(t, http.MethodGet, srv.URL+"/api/jobs", "", &list))
+	require.Len(t, list.Jobs, 2)
+
+	// the first event is job 1 queued
+	sc := bufio.NewScanner(resp.Body)
+	require.True(t, sc.Scan())
+	require.Equal(t, "event: job", sc.Text())
+	require.True(t, sc.Scan())
+	require.True(t, strings.HasPrefix(sc.Text(), `data: {"id":1,"target":"BV1pP4y1b7iP"`), sc.Text())
+	require.Contains(t, sc.Text(), `"state":"queued"`)
+
+	waitState(t, m, 1, StateRunning)
+	var job Job
+	require.Equal(t, http.StatusOK, doRequest(t, http.MethodPost, srv.URL+"/api/jobs/2/pause", "", &job))
+	require.Equal(t, StatePaused, job.State)
+	require.Equal(t, http.StatusConflict, doRequest(t, http.MethodPost, srv.URL+"/api/jobs/2/pause", "", &errResp))
+	require.Equal(t, "operation not allowed in state of job: paused", errResp.Error)
+	require.Equal(t, http.StatusOK, doRequest(t, http.MethodPost, srv.URL+"/api/jobs/1/cancel", "", &job))
+	require.Equal(t, StateCanceled, job.State)
+
+	require.Equal(t, http.StatusOK, doRequest(t, http.MethodGet, srv.URL+"/api/jobs/2", "", &job))
+	require.Equal(t, StatePaused, job.State)
+	require.Equal(t, http.StatusNotFound, doRequest(t, http.MethodGet, srv.URL+"/api/jobs/9", "", &errResp))
+	require.Equal(t, http.StatusNotFound, doRequest(t, http.MethodPost, srv.URL+"/api/jobs/1/stop", "", &errResp))
+	require.Equal(t, http.StatusMethodNotAllowed, doRequest(t, http.MethodGet, srv.URL+"/api/jobs/1/resume", "", &errResp))
+
+	var video meta.ListingVideo
+	require.Equal(t, http.StatusOK, doRequest(t, http.MethodGet,
+		srv.URL+"/api/info?target="+url.QueryEscape("https://www.bilibili.com/video/BV1pP4y1b7iP"), "", &video))
+	require.Equal(t, "BV1pP4y1b7iP", video.ID)
+	require.Len(t, video.Pages[0].Streams, 2)
+	require.True(t, video.Pages[0].Streams[0].Selected)
+	require.Equal(t, http.StatusBadRequest, doRequest(t, http.MethodGet, srv.URL+"/api/info?target=", "", &errResp))
+	require.Equal(t, http.StatusBadGateway, doRequest(t, http.MethodGet, srv.URL+"/api/info?target=BVbad", "", &errResp))
+	require.Equal(t, "code not 0: -404", errResp.Error)
+
+	metricsResp, err := http.Get(srv.URL + "/metrics")
+	require.Nil(t, err)
+	body, err := io.ReadAll(metricsResp.Body)
+	metricsResp.Body.Close()
+	require.Nil(t, err)
+	require.Contains(t, string(body), `bilidown_jobs{state="canceled"} 1`)
+	require.Contains(t, string(body), `bilidown_jobs{state="paused"} 1`)
+	require.Contains(t, string(body), "go_goroutines")
+}
+
+func TestHandlerWeb(t *testing.T) {
+	m, err := NewManager(newFakeRunner(t, 1), NewStore(filepath.Join(t.TempDir(), "jobs.json")), 1)
+	require.Nil(t, err)
+	srv := httptest.NewServer(NewHandler(m, ""))
+	defer srv.Close()
+
+	for path, typ := range map[string]string{
+		"/":          "text/html; charset=utf-8",
+		"/app.js":    "text/javascript; charset=utf-8",
+		"/style.css": "text/css; charset=utf-8",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		require.Nil(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		require.Equal(t, typ, resp.Header.Get("Content-Type"), path)
+		require.NotEmpty(t, body, path)
+	}
+	resp, err := http.Get(srv.URL + "/missing.js")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHandlerGuard(t *testing.T) {
+	m, err := NewManager(newFakeRunner(t, 1), NewStore(filepath.Join(t.TempDir(), "jobs.json")), 1)
+	require.Nil(t, err)
+	serve := func(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	enqueue := `{"targets": ["BV1pP4y1b7iP"]}`
+
+	h := NewHandler(m, "")
+	// forms and text/plain bodies can be posted by any page without preflight
+	require.Equal(t, http.StatusUnsupportedMediaType, serve(h, http.MethodPost, "http://127.0.0.1:8080/api/jobs", enqueue,
+		map[string]string{"Content-Type": "text/plain"}).Code)
+	require.Equal(t, http.StatusUnsupportedMediaType, serve(h, http.MethodPost, "http://127.0.0.1:8080/api/jobs/1/cancel", "", nil).Code)
+	require.Equal(t, http.StatusForbidden, serve(h, http.MethodPost, "http://127.0.0.1:8080/api/jobs", enqueue,
+		map[string]string{"Content-Type": "application/json", "Origin": "https://evil.example"}).Code)
+	// name of other site resolved to this server
+	require.Equal(t, http.StatusForbidden, serve(h, http.MethodGet, "http://evil.example:8080/api/jobs", "", nil).Code)
+	require.Equal(t, http.StatusCreated, serve(h, http.MethodPost, "http://127.0.0.1:8080/api/jobs", enqueue,
+		map[string]string{"Content-Type": "application/json; charset=utf-8", "Origin": "http://127.0.0.1:8080"}).Code)
+	require.Equal(t, http.StatusOK, serve(h, http.MethodGet, "http://localhost:8080/api/jobs", "", nil).Code)
+	require.Equal(t, http.StatusOK, serve(h, http.MethodGet, "http://[::1]:8080/metrics", "", nil).Code)
+
+	h = NewHandler(m, "secret")
+	require.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "http://127.0.0.1:8080/api/jobs", "", nil).Code)
+	require.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "http://127.0.0.1:8080/metrics", "", nil).Code)
+	require.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "http://127.0.0.1:8080/api/jobs", "",
+		map[string]string{"Authorization": "Bearer wrong"}).Code)
+	// host names are fine with token
+	require.Equal(t, http.StatusOK, serve(h, http.MethodGet, "http://media.lan:8080/api/jobs", "",
+		map[string]string{"Authorization": "Bearer secret"}).Code)
+	require.Equal(t, http.StatusCreated, serve(h, http.MethodPost, "http://media.lan:8080/api/jobs", enqueue,
+		map[string]string{"Content-Type": "application/json", "Authorization": "Bearer secret"}).Code)
+	require.Equal(t, http.StatusUnsupportedMediaType, serve(h, http.MethodPost, "http://media.lan:8080/api/jobs", enqueue,
+		map[string]string{"Authorization": "Bearer secret"}).Code)
+
+	// web ui is served without token, and keeps token in cookie after opened with it
+	require.Equal(t, http.StatusOK, serve(h, http.MethodGet, "http://media.lan:8080/", "", nil).Code)
+	require.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "http://media.lan:8080/?token=wrong", "", nil).Code)
+	w := serve(h, http.MethodGet, "http://media.lan:8080/?token=secret", "", nil)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "/", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	require.True(t, cookies[0].HttpOnly)
+	require.Equal(t, http.StatusOK, serve(h, http.MethodGet, "http://media.lan:8080/api/jobs", "",
+		map[string]string{"Cookie": cookies[0].String()}).Code)
+	require.Equal(t, http.StatusNotFound, serve(h, http.MethodPost, "http://media.lan:8080/api/jobs/99/cancel", "",
+		map[string]string{"Cookie": cookies[0].String(), "Content-Type": "application/json", "Origin": "http://media.lan:8080"}).Code)
+}
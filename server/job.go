@@ -0,0 +1,69 @@
+package server
+
+import (
+	"time"
+)
+
+// State of job
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+	StateCanceled  State = "canceled"
+)
+
+// states are all states of job
+var states = []State{StateQueued, StateRunning, StatePaused, StateCompleted, StateFailed, StateCanceled}
+
+// Job is a video or bangumi queued to download
+type Job struct {
+	ID      int64     `json:"id"`
+	Target  string    `json:"target"`            // id or url given
+	VideoID string    `json:"video_id"`          // id parsed from target
+	Title   string    `json:"title,omitempty"`   // known after job runs
+	Pages   string    `json:"pages,omitempty"`   // pages to download like 1,3-5, all if empty
+	Quality string    `json:"quality,omitempty"` // quality like 1080P, quality of config if empty
+	State   State     `json:"state"`
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Files   []*File   `json:"files"`           // pages matched, known after job runs
+	Speed   int64     `json:"speed,omitempty"` // bytes per second of file being downloaded
+}
+
+// File is a page of job
+type File struct {
+	Page       int64  `json:"page"`
+	VideoID    string `json:"video_id"`
+	Part       string `json:"part"`
+	Name       string `json:"name,omitempty"` // known when download begins
+	Size       int64  `json:"size,omitempty"`
+	Downloaded int64  `json:"downloaded"`
+	Done       bool   `json:"done"`
+	Error      string `json:"error,omitempty"`
+}
+
+// clone copy job so it can be read without lock of manager
+func (j *Job) clone() *Job {
+	c := *j
+	c.Files = make([]*File, len(j.Files))
+	for i, f := range j.Files {
+		fc := *f
+		c.Files[i] = &fc
+	}
+	return &c
+}
+
+// file return file of page, nil if not found
+func (j *Job) file(page int64) *File {
+	for _, f := range j.Files {
+		if f.Page == page {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/meta"
+	"github.com/rammiah/bili-downloader/metrics"
+)
+
+var (
+	// ErrNotFound is returned if job id not exists
+	ErrNotFound = errors.New("job not found")
+	// ErrState is returned if job can't be paused, resumed or canceled in its state
+	ErrState = errors.New("operation not allowed in state of job")
+)
+
+// speedAlpha is weight of the newest sample in smoothed speed
+const speedAlpha = 0.3
+
+// Runner download pages of jobs, it's provided by command line so files are named and post processed by config
+type Runner interface {
+	// Info get pages of video id, qualities are of the first page only
+	Info(videoID string) (*meta.ListingVideo, error)
+	// Resolve get pages of video id matched by pages
+	Resolve(videoID, pages string) ([]*download.VideoInfo, error)
+	// Download video of quality to file until ctx is done, quality of config is used if empty,
+	// start is called with file name and downloader before download begins
+	Download(ctx context.Context, video *download.VideoInfo, quality string,
+		start func(name string, d *download.VideoDownloader)) error
+}
+
+// EventType type of event of manager
+type EventType string
+
+const (
+	// EventJob is sent when job is added, changes state or a file of it begins or ends
+	EventJob EventType = "job"
+	// EventProgress is sent when bytes of job are downloaded
+	EventProgress EventType = "progress"
+)
+
+// Event is change of a job, job is a copy
+type Event struct {
+	Type EventType
+	Job  *Job
+}
+
+// run is a running job
+type run struct {
+	cancel context.CancelFunc
+	next   State // state after job stopped by pause, cancel or close, empty if not stopped
+}
+
+// Manager run jobs in order they are queued, jobs are saved to store when they change
+type Manager struct {
+	mu      sync.Mutex
+	runner  Runner
+	store   *Store
+	slots   int
+	jobs    []*Job
+	nextID  int64
+	running map[int64]*run
+	subs    map[chan *Event]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewManager create manager run slots jobs at the same time, jobs are loaded from store,
+// those running when saved are queued again
+func NewManager(runner Runner, store *Store, slots int) (*Manager, error) {
+	jobs, nextID, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if slots <= 0 {
+		slots = 1
+	}
+	for _, job := range jobs {
+		if job.State == StateRunning {
+			job.State = StateQueued
+		}
+		job.Speed = 0
+		for _, f := range job.Files {
+			if !f.Done {
+				// file of page is downloaded again
+				f.Downloaded = 0
+			}
+		}
+	}
+	return &Manager{
+		runner:  runner,
+		store:   store,
+		slots:   slots,
+		jobs:    jobs,
+		nextID:  nextID,
+		running: map[int64]*run{},
+		subs:    map[chan *Event]struct{}{},
+	}, nil
+}
+
+// Start run queued jobs
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.count()
+	m.schedule()
+}
+
+// Close stop running jobs and wait them exit, they are queued again when manager is created next time
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	for _, r := range m.running {
+		r.next = StateQueued
+		r.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// Info get pages of video id and qualities of its first page
+func (m *Manager) Info(videoID string) (*meta.ListingVideo, error) {
+	return m.runner.Info(videoID)
+}
+
+// Enqueue add jobs of targets, targets are ids or urls of video, nothing is added if any of them is invalid.
+// quality is best, qn number or name like 1080P, quality of config is used if empty
+func (m *Manager) Enqueue(targets []string, pages, quality string) ([]*Job, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no target given")
+	}
+	if quality != "" {
+		if _, err := consts.ParseQuality(quality); err != nil {
+			return nil, err
+		}
+	}
+	ids := make([]string, 0, len(targets))
+	for _, target := range targets {
+		id, err := download.ParseId(target)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			return nil, errors.New("empty target")
+		}
+		ids = append(ids, id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	added := make([]*Job, 0, len(ids))
+	for i, id := range ids {
+		job := &Job{
+			ID:      m.nextID,
+			Target:  targets[i],
+			VideoID: id,
+			Pages:   pages,
+			Quality: quality,
+			State:   StateQueued,
+			Created: now,
+			Updated: now,
+			Files:   []*File{},
+		}
+		m.nextID++
+		m.jobs = append(m.jobs, job)
+		added = append(added, job.clone())
+		m.publish(EventJob, job)
+	}
+	m.save()
+	m.schedule()
+	return added, nil
+}
+
+// Jobs return copy of all jobs in order they are added
+func (m *Manager) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job.clone())
+	}
+	return jobs
+}
+
+// Job return copy of job id
+func (m *Manager) Job(id int64) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.find(id)
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	return job.clone(), nil
+}
+
+// Pause stop job, file being downloaded is downloaded again when resumed
+func (m *Manager) Pause(id int64) (*Job, error) {
+	return m.change(id, func(job *Job) error {
+		if job.State != StateQueued && job.State != StateRunning {
+			return ErrState
+		}
+		m.stop(job, StatePaused)
+		job.State = StatePaused
+		return nil
+	})
+}
+
+// Resume queue paused or failed job again, files done are skipped
+func (m *Manager) Resume(id int64) (*Job, error) {
+	return m.change(id, func(job *Job) error {
+		if job.State != StatePaused && job.State != StateFailed {
+			return ErrState
+		}
+		// paused job may be still exiting
+		m.stop(job, StateQueued)
+		job.State = StateQueued
+		job.Error = ""
+		return nil
+	})
+}
+
+// Cancel stop job, it can't be resumed
+func (m *Manager) Cancel(id int64) (*Job, error) {
+	return m.change(id, func(job *Job) error {
+		if job.State == StateCompleted || job.State == StateCanceled {
+			return ErrState
+		}
+		m.stop(job, StateCanceled)
+		job.State = StateCanceled
+		return nil
+	})
+}
+
+// Subscribe receive events of jobs until cancel is called, events are dropped if receiver is slow
+func (m *Manager) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, 64)
+	m.mu.Lock()
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+	return ch, func() {
+		m.mu.Lock()
+		delete(m.subs, ch)
+		m.mu.Unlock()
+	}
+}
+
+// change job id by fn with lock held, then save and schedule jobs
+func (m *Manager) change(id int64, fn func(job *Job) error) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.find(id)
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	if err := fn(job); err != nil {
+		return nil, fmt.Errorf("%w: %v", err, job.State)
+	}
+	job.Updated = time.Now()
+	m.publish(EventJob, job)
+	m.save()
+	m.schedule()
+	return job.clone(), nil
+}
+
+func (m *Manager) find(id int64) *Job {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// stop job if it's running, job takes state next after it exits
+func (m *Manager) stop(job *Job, next State) {
+	if r, ok := m.running[job.ID]; ok {
+		r.next = next
+		r.cancel()
+	}
+}
+
+// schedule start queued jobs in order until slots are full
+func (m *Manager) schedule() {
+	if m.closed {
+		return
+	}
+	for _, job := range m.jobs {
+		if len(m.running) >= m.slots {
+			return
+		}
+		if _, ok := m.running[job.ID]; ok || job.State != StateQueued {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.running[job.ID] = &run{cancel: cancel}
+		job.State = StateRunning
+		job.Updated = time.Now()
+		m.publish(EventJob, job)
+		m.save()
+		m.wg.Add(1)
+		go m.run(ctx, job)
+	}
+}
+
+// run download files of job
+func (m *Manager) run(ctx context.Context, job *Job) {
+	defer m.wg.Done()
+	videos, err := m.runner.Resolve(job.VideoID, job.Pages)
+	if err != nil {
+		m.finish(job, err)
+		return
+	}
+
+	m.mu.Lock()
+	files := make([]*File, 0, len(videos))
+	for _, video := range videos {
+		f := job.file(video.Page)
+		if f == nil {
+			f = &File{Page: video.Page, VideoID: video.VideoID, Part: video.PartName}
+		}
+		f.Error = ""
+		files = append(files, f)
+	}
+	job.Files = files
+	if len(videos) > 0 {
+		job.Title = videos[0].Title
+	}
+	m.publish(EventJob, job)
+	m.save()
+	m.mu.Unlock()
+
+	failed := 0
+	for i, video := range videos {
+		f := files[i]
+		if f.Done {
+			continue
+		}
+		err := m.runner.Download(ctx, video, job.Quality, func(name string, d *download.VideoDownloader) {
+			m.mu.Lock()
+			f.Name = name
+			m.publish(EventJob, job)
+			m.save()
+			m.mu.Unlock()
+			d.AddObserver(&fileObserver{m: m, job: job, file: f})
+		})
+		// a file finished as job is paused or canceled is done too, so it's not downloaded again on resume
+		m.mu.Lock()
+		switch {
+		case err == nil:
+			f.Done = true
+			f.Downloaded = f.Size
+		case ctx.Err() == nil:
+			log.Errorf("job %v page %v error: %v", job.ID, video.Page, err)
+			f.Error = err.Error()
+			failed++
+		}
+		m.publish(EventJob, job)
+		m.save()
+		m.mu.Unlock()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%v of %v files failed", failed, len(files))
+	}
+	m.finish(job, err)
+}
+
+// finish set state of job by result after it exits, and start next jobs
+func (m *Manager) finish(job *Job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.running[job.ID]
+	delete(m.running, job.ID)
+	r.cancel()
+
+	switch {
+	case r.next != "":
+		job.State = r.next
+	case err != nil:
+		job.State = StateFailed
+		job.Error = err.Error()
+	default:
+		job.State = StateCompleted
+	}
+	job.Speed = 0
+	job.Updated = time.Now()
+	m.publish(EventJob, job)
+	m.save()
+	m.schedule()
+}
+
+// save write jobs to store, errors are logged only
+func (m *Manager) save() {
+	if err := m.store.Save(m.jobs, m.nextID); err != nil {
+		log.Errorf("save jobs error: %v", err)
+	}
+	m.count()
+}
+
+// count jobs by state in metrics
+func (m *Manager) count() {
+	counts := map[State]int{}
+	for _, job := range m.jobs {
+		counts[job.State]++
+	}
+	for _, state := range states {
+		metrics.Jobs.WithLabelValues(string(state)).Set(float64(counts[state]))
+	}
+}
+
+// publish send copy of job to subscribers
+func (m *Manager) publish(typ EventType, job *Job) {
+	if len(m.subs) == 0 {
+		return
+	}
+	e := &Event{Type: typ, Job: job.clone()}
+	for ch := range m.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// fileObserver update progress of file being downloaded
+type fileObserver struct {
+	download.NopObserver
+	m    *Manager
+	job  *Job
+	file *File
+
+	last  time.Time
+	speed float64
+}
+
+func (o *fileObserver) OnStart(info *download.DownloadInfo, fragments int) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	o.file.Size = info.Size
+	o.file.Downloaded = 0
+	o.last = time.Now()
+}
+
+func (o *fileObserver) OnProgress(done, total int64) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	now := time.Now()
+	if dt := now.Sub(o.last).Seconds(); dt > 0 {
+		cur := float64(done-o.file.Downloaded) / dt
+		if cur < 0 {
+			cur = 0
+		}
+		o.speed = speedAlpha*cur + (1-speedAlpha)*o.speed
+	}
+	o.last = now
+	o.file.Downloaded = done
+	o.job.Speed = int64(o.speed)
+	o.m.publish(EventProgress, o.job)
+}
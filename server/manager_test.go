@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/rammiah/bili-downloader/download"
+	"github.com/rammiah/bili-downloader/meta"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner resolve every id to pages, downloads wait for release if block is set
+type fakeRunner struct {
+	dir     string
+	pages   int64
+	block   bool
+	release chan struct{}
+	fail    map[int64]error                 // by page
+	done    func(video *download.VideoInfo) // called before download returns
+
+	mu        sync.Mutex
+	downloads []string
+}
+
+func newFakeRunner(t *testing.T, pages int64) *fakeRunner {
+	return &fakeRunner{dir: t.TempDir(), pages: pages, release: make(chan struct{}), fail: map[int64]error{}}
+}
+
+func (r *fakeRunner) Resolve(videoID, pages string) ([]*download.VideoInfo, error) {
+	if videoID == "BVbad" {
+		return nil, errors.New("code not 0: -404")
+	}
+	var videos []*download.VideoInfo
+	for page := int64(1); page <= r.pages; page++ {
+		videos = append(videos, &download.VideoInfo{VideoID: videoID, Page: page, PartName: fmt.Sprint("p", page)})
+	}
+	return videos, nil
+}
+
+func (r *fakeRunner) Info(videoID string) (*meta.ListingVideo, error) {
+	videos, err := r.Resolve(videoID, "")
+	if err != nil {
+		return nil, err
+	}
+	info := &download.DownloadInfo{Qn: consts.Qn1080P, AcceptQuality: []int64{consts.Qn1080P, consts.Qn720P}}
+	return meta.ListVideo(videoID, videos, []*download.DownloadInfo{info}, nil), nil
+}
+
+func (r *fakeRunner) Download(ctx context.Context, video *download.VideoInfo, quality string,
+	start func(string, *download.VideoDownloader)) error {
+	name := filepath.Join(r.dir, fmt.Sprintf("%v-%v.flv", video.VideoID, video.Page))
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	start(name, download.NewVideoDownloader(&download.DownloadInfo{Size: 10}, f))
+
+	r.mu.Lock()
+	r.downloads = append(r.downloads, fmt.Sprintf("%v-%v@%v", video.VideoID, video.Page, quality))
+	r.mu.Unlock()
+	if r.block {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if r.done != nil {
+		r.done(video)
+	}
+	return r.fail[video.Page]
+}
+
+func waitState(t *testing.T, m *Manager, id int64, state State) *Job {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		job, err := m.Job(id)
+		require.Nil(t, err)
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %v is %v, want %v", id, job.State, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagerRun(t *testing.T) {
+	runner := newFakeRunner(t, 2)
+	runner.fail[2] = errors.New("timeout")
+	store := NewStore(filepath.Join(t.TempDir(), "jobs.json"))
+	m, err := NewManager(runner, store, 1)
+	require.Nil(t, err)
+	m.Start()
+	defer m.Close()
+
+	_, err = m.Enqueue([]string{"BV1pP4y1b7iP", "https://evil.com/video/BV1"}, "", "")
+	require.NotNil(t, err)
+	_, err = m.Enqueue([]string{"BV1pP4y1b7iP"}, "", "9K")
+	require.NotNil(t, err)
+	require.Empty(t, m.Jobs())
+
+	jobs, err := m.Enqueue([]string{"https://www.bilibili.com/video/BV1pP4y1b7iP?p=1", "BVbad"}, "1-2", "720P")
+	require.Nil(t, err)
+	require.Len(t, jobs, 2)
+	require.Equal(t, int64(1), jobs[0].ID)
+	require.Equal(t, "BV1pP4y1b7iP", jobs[0].VideoID)
+
+	job := waitState(t, m, 1, StateFailed)
+	require.Equal(t, "1 of 2 files failed", job.Error)
+	require.True(t, job.Files[0].Done)
+	require.Equal(t, "timeout", job.Files[1].Error)
+	job = waitState(t, m, 2, StateFailed)
+	require.Equal(t, "code not 0: -404", job.Error)
+
+	// resumed job download failed files only
+	delete(runner.fail, 2)
+	_, err = m.Resume(1)
+	require.Nil(t, err)
+	job = waitState(t, m, 1, StateCompleted)
+	require.Empty(t, job.Error)
+	require.Empty(t, job.Files[1].Error)
+	require.Equal(t, []string{"BV1pP4y1b7iP-1@720P", "BV1pP4y1b7iP-2@720P", "BV1pP4y1b7iP-2@720P"}, runner.downloads)
+
+	_, err = m.Cancel(1)
+	require.True(t, errors.Is(err, ErrState))
+	_, err = m.Pause(3)
+	require.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestManagerPause(t *testing.T) {
+	runner := newFakeRunner(t, 1)
+	runner.block = true
+	file := filepath.Join(t.TempDir(), "jobs.json")
+	m, err := NewManager(runner, NewStore(file), 1)
+	require.Nil(t, err)
+	m.Start()
+
+	events, cancel := m.Subscribe()
+	defer cancel()
+	_, err = m.Enqueue([]string{"BV1", "BV2"}, "", "")
+	require.Nil(t, err)
+	waitState(t, m, 1, StateRunning)
+	require.Equal(t, StateQueued, waitState(t, m, 2, StateQueued).State)
+
+	// pause and resume at once, job runs again after it exits
+	job, err := m.Pause(1)
+	require.Nil(t, err)
+	require.Equal(t, StatePaused, job.State)
+	_, err = m.Resume(1)
+	require.Nil(t, err)
+	waitState(t, m, 1, StateRunning)
+
+	job, err = m.Cancel(1)
+	require.Nil(t, err)
+	require.Equal(t, StateCanceled, job.State)
+	waitState(t, m, 2, StateRunning)
+	_, err = m.Resume(1)
+	require.True(t, errors.Is(err, ErrState))
+
+	// running job is queued again after restart
+	m.Close()
+	m, err = NewManager(runner, NewStore(file), 1)
+	require.Nil(t, err)
+	jobs := m.Jobs()
+	require.Equal(t, StateCanceled, jobs[0].State)
+	require.Equal(t, StateQueued, jobs[1].State)
+	require.Equal(t, "p1", jobs[1].Files[0].Part)
+
+	var states []State
+	for len(events) > 0 {
+		e := <-events
+		if e.Job.ID == 1 && e.Type == EventJob {
+			states = append(states, e.Job.State)
+		}
+	}
+	require.Contains(t, states, StatePaused)
+	require.Contains(t, states, StateCanceled)
+}
+
+func TestManagerPauseDone(t *testing.T) {
+	runner := newFakeRunner(t, 2)
+	m, err := NewManager(runner, NewStore(filepath.Join(t.TempDir(), "jobs.json")), 1)
+	require.Nil(t, err)
+	// job is paused as its first file completes
+	runner.done = func(video *download.VideoInfo) {
+		if video.Page == 1 {
+			runner.done = nil
+			_, err := m.Pause(1)
+			require.Nil(t, err)
+		}
+	}
+	m.Start()
+	defer m.Close()
+
+	_, err = m.Enqueue([]string{"BV1"}, "", "")
+	require.Nil(t, err)
+	waitState(t, m, 1, StatePaused)
+	_, err = m.Resume(1)
+	require.Nil(t, err)
+	job := waitState(t, m, 1, StateCompleted)
+	require.True(t, job.Files[0].Done)
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	require.Equal(t, []string{"BV1-1@", "BV1-2@"}, runner.downloads)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// storeVersion is version of state file
+const storeVersion = 1
+
+// Store keep jobs in a json file across restarts
+type Store struct {
+	file string
+}
+
+type storeData struct {
+	Version int    `json:"version"`
+	NextID  int64  `json:"next_id"`
+	Jobs    []*Job `json:"jobs"`
+}
+
+// NewStore create store of file
+func NewStore(file string) *Store {
+	return &Store{file: file}
+}
+
+// Load read jobs and the next job id, nothing is returned if file not exists
+func (s *Store) Load() ([]*Job, int64, error) {
+	buf, err := ioutil.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 1, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var data storeData
+	if err := json.Unmarshal(buf, &data); err != nil {
+		return nil, 0, fmt.Errorf("parse %v error: %w", s.file, err)
+	}
+	if data.Version != storeVersion {
+		return nil, 0, fmt.Errorf("%v: unsupported version %v", s.file, data.Version)
+	}
+	if data.NextID <= 0 {
+		data.NextID = 1
+	}
+	return data.Jobs, data.NextID, nil
+}
+
+// Save write jobs to a temp file and rename it, so file is never half written
+func (s *Store) Save(jobs []*Job, nextID int64) error {
+	buf, err := json.MarshalIndent(&storeData{Version: storeVersion, NextID: nextID, Jobs: jobs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0700); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
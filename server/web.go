@@ -0,0 +1,21 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles is the web ui, plain html and js without build step
+//
+//go:embed web
+var webFiles embed.FS
+
+// webHandler serve files of web ui
+func webHandler() http.Handler {
+	sub, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,246 @@
+'use strict';
+
+const $ = (sel, el = document) => el.querySelector(sel);
+
+const jobs = new Map(); // id -> job
+const cards = new Map(); // id -> card element of job
+
+async function api(method, path, body) {
+  const resp = await fetch(path, {
+    method,
+    // api accepts json only, so pages of other sites can't send simple requests to it
+    headers: method === 'GET' ? {} : {'Content-Type': 'application/json'},
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  const data = await resp.json();
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+function formatBytes(n) {
+  const units = ['B', 'KiB', 'MiB', 'GiB'];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i ? n.toFixed(1) : n) + ' ' + units[i];
+}
+
+function formatDuration(s) {
+  const m = Math.floor(s / 60);
+  return m + ':' + String(s % 60).padStart(2, '0');
+}
+
+// formatPages join sorted pages to ranges like 1-3,5
+function formatPages(pages) {
+  const ranges = [];
+  for (let i = 0; i < pages.length; i++) {
+    let j = i;
+    while (j + 1 < pages.length && pages[j + 1] === pages[j] + 1) {
+      j++;
+    }
+    ranges.push(i === j ? String(pages[i]) : pages[i] + '-' + pages[j]);
+    i = j;
+  }
+  return ranges.join(',');
+}
+
+function targets() {
+  return $('#targets').value.split('\n').map(s => s.trim()).filter(s => s);
+}
+
+async function lookup() {
+  const button = $('#lookup');
+  button.disabled = true;
+  try {
+    await Promise.all(targets().map(async target => {
+      const card = $('#video-tmpl').content.firstElementChild.cloneNode(true);
+      $('.title', card).textContent = target;
+      $('.meta', card).textContent = 'looking up...';
+      $('.close', card).onclick = () => card.remove();
+      $('#videos').append(card);
+      try {
+        renderVideo(card, target, await api('GET', '/api/info?target=' + encodeURIComponent(target)));
+      } catch (e) {
+        $('.meta', card).textContent = '';
+        $('.error', card).textContent = e.message;
+        $('.actions', card).remove();
+      }
+    }));
+    $('#targets').value = '';
+  } finally {
+    button.disabled = false;
+  }
+}
+
+function renderVideo(card, target, video) {
+  $('.title', card).textContent = video.title || target;
+  $('.title', card).title = target;
+  $('.meta', card).textContent = [video.uploader, video.pages.length + ' pages'].filter(s => s).join(' · ');
+
+  const boxes = video.pages.map(p => {
+    const label = document.createElement('label');
+    const box = document.createElement('input');
+    box.type = 'checkbox';
+    box.checked = true;
+    box.value = p.page;
+    label.title = p.part;
+    label.append(box, ` P${p.page} ${p.part} (${formatDuration(p.duration)})`);
+    $('.pages', card).append(label);
+    return box;
+  });
+  const all = $('.all input', card);
+  all.onchange = () => boxes.forEach(box => box.checked = all.checked);
+  boxes.forEach(box => box.onchange = () => all.checked = boxes.every(b => b.checked));
+  if (boxes.length <= 1) {
+    $('.pages', card).hidden = true;
+    $('.all', card).hidden = true;
+  }
+
+  const select = $('.quality', card);
+  const first = video.pages[0] || {};
+  if (first.error) {
+    $('.error', card).textContent = 'qualities unknown: ' + first.error;
+  }
+  select.append(new Option('default quality', ''));
+  for (const s of first.streams || []) {
+    let text = s.quality;
+    if (s.need) {
+      text += ' (' + s.need + ')';
+    }
+    if (s.selected && s.size) {
+      text += ' · ' + formatBytes(s.size);
+    }
+    select.append(new Option(text, String(s.qn), false, s.selected));
+  }
+
+  $('.add', card).onclick = async () => {
+    const pages = boxes.filter(box => box.checked).map(box => Number(box.value));
+    if (!pages.length) {
+      $('.error', card).textContent = 'no page selected';
+      return;
+    }
+    try {
+      await api('POST', '/api/jobs', {
+        targets: [target],
+        pages: pages.length === boxes.length ? '' : formatPages(pages),
+        quality: select.value,
+      });
+      card.remove();
+    } catch (e) {
+      $('.error', card).textContent = e.message;
+    }
+  };
+}
+
+async function queueDirectly() {
+  const list = targets();
+  if (!list.length) {
+    return;
+  }
+  try {
+    await api('POST', '/api/jobs', {targets: list});
+    $('#targets').value = '';
+  } catch (e) {
+    alert(e.message);
+  }
+}
+
+function jobCard(job) {
+  let card = cards.get(job.id);
+  if (!card) {
+    card = $('#job-tmpl').content.firstElementChild.cloneNode(true);
+    for (const op of ['pause', 'resume', 'cancel']) {
+      $('.' + op, card).onclick = () => api('POST', `/api/jobs/${job.id}/${op}`).catch(e => alert(e.message));
+    }
+    cards.set(job.id, card);
+    $('#jobs').prepend(card);
+  }
+  return card;
+}
+
+function renderJob(job) {
+  jobs.set(job.id, job);
+  $('#empty').hidden = true;
+  const card = jobCard(job);
+
+  $('.title', card).textContent = job.title || job.target;
+  $('.title', card).title = job.target;
+  const state = $('.state', card);
+  state.textContent = job.state;
+  state.className = 'state ' + job.state;
+  $('.speed', card).textContent = job.state === 'running' && job.speed ? formatBytes(job.speed) + '/s' : '';
+  $('.pause', card).hidden = job.state !== 'queued' && job.state !== 'running';
+  $('.resume', card).hidden = job.state !== 'paused' && job.state !== 'failed';
+  $('.cancel', card).hidden = job.state === 'completed' || job.state === 'canceled';
+
+  let size = 0, downloaded = 0, done = 0;
+  const files = $('.files', card);
+  files.textContent = '';
+  for (const f of job.files) {
+    size += f.size || 0;
+    downloaded += f.done ? f.size || 0 : f.downloaded;
+    if (f.done) {
+      done++;
+    }
+    const li = document.createElement('li');
+    let text = `P${f.page} ${f.part}`;
+    if (f.done) {
+      text += ' · done';
+    } else if (f.size) {
+      text += ` · ${formatBytes(f.downloaded)} / ${formatBytes(f.size)}`;
+    }
+    if (f.error) {
+      text += ' · ' + f.error;
+    }
+    li.textContent = text;
+    files.append(li);
+  }
+  let percent = size ? downloaded / size * 100 : 0;
+  if (job.state === 'completed') {
+    percent = 100;
+  }
+  $('.bar div', card).style.width = percent.toFixed(1) + '%';
+  const summary = [];
+  if (job.files.length) {
+    summary.push(`${done} / ${job.files.length} files`);
+  }
+  if (size) {
+    summary.push(`${formatBytes(downloaded)} / ${formatBytes(size)}`);
+  }
+  if (job.quality) {
+    summary.push('quality ' + job.quality);
+  }
+  $('.summary', card).textContent = summary.join(' · ');
+  $('.error', card).textContent = job.error || '';
+}
+
+async function loadJobs() {
+  const list = await api('GET', '/api/jobs');
+  list.jobs.forEach(renderJob);
+}
+
+function connect() {
+  const status = $('#status');
+  const events = new EventSource('/api/events');
+  const onEvent = e => renderJob(JSON.parse(e.data));
+  events.addEventListener('job', onEvent);
+  events.addEventListener('progress', onEvent);
+  events.onopen = () => {
+    status.textContent = 'online';
+    status.className = 'status online';
+    // events missed while offline
+    loadJobs().catch(e => console.error(e));
+  };
+  events.onerror = () => {
+    status.textContent = 'offline';
+    status.className = 'status offline';
+  };
+}
+
+$('#lookup').onclick = lookup;
+$('#queue').onclick = queueDirectly;
+connect();
@@ -0,0 +1,134 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// Cue is a line of subtitle
+type Cue struct {
+	From    float64 `json:"from"` // seconds
+	To      float64 `json:"to"`   // seconds
+	Content string  `json:"content"`
+}
+
+// BCC is subtitle format used by bilibili
+type BCC struct {
+	FontSize        float64 `json:"font_size"`
+	FontColor       string  `json:"font_color"`
+	BackgroundAlpha float64 `json:"background_alpha"`
+	BackgroundColor string  `json:"background_color"`
+	Stroke          string  `json:"Stroke"`
+	Body            []*Cue  `json:"body"`
+}
+
+// ParseBCC parse bcc json subtitle
+func ParseBCC(r io.Reader) (*BCC, error) {
+	bcc := &BCC{}
+	if err := json.NewDecoder(r).Decode(bcc); err != nil {
+		return nil, err
+	}
+	return bcc, nil
+}
+
+// WriteSRT write cues as SubRip subtitle
+func WriteSRT(w io.Writer, cues []*Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, cue := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatTime(cue.From, ","), formatTime(cue.To, ","), normalize(cue.Content))
+	}
+	return bw.Flush()
+}
+
+// WriteVTT write cues as WebVTT subtitle
+func WriteVTT(w io.Writer, cues []*Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	for _, cue := range cues {
+		fmt.Fprintf(bw, "%s --> %s\n%s\n\n",
+			formatTime(cue.From, "."), formatTime(cue.To, "."), replacer.Replace(normalize(cue.Content)))
+	}
+	return bw.Flush()
+}
+
+// Write write cues in format, srt or vtt
+func Write(w io.Writer, cues []*Cue, format string) error {
+	switch format {
+	case "srt":
+		return WriteSRT(w, cues)
+	case "vtt":
+		return WriteVTT(w, cues)
+	default:
+		return fmt.Errorf("unsupported subtitle format %v", format)
+	}
+}
+
+// normalize remove empty lines which terminate a cue
+func normalize(content string) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// formatTime format seconds as hh:mm:ss,mmm, sep is the millisecond separator
+func formatTime(sec float64, sep string) string {
+	ms := int64(math.Round(sec * 1000))
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// ISO639 convert bilibili language like zh-CN/ai-en to ISO 639-2 code used by mp4 metadata
+func ISO639(lan string) string {
+	lan = strings.TrimPrefix(strings.ToLower(lan), "ai-")
+	if idx := strings.IndexAny(lan, "-_"); idx != -1 {
+		lan = lan[:idx]
+	}
+	switch lan {
+	case "zh":
+		return "chi"
+	case "en":
+		return "eng"
+	case "ja":
+		return "jpn"
+	case "ko":
+		return "kor"
+	case "es":
+		return "spa"
+	case "fr":
+		return "fre"
+	case "de":
+		return "ger"
+	case "ru":
+		return "rus"
+	case "pt":
+		return "por"
+	case "th":
+		return "tha"
+	case "vi":
+		return "vie"
+	case "id":
+		return "ind"
+	case "ar":
+		return "ara"
+	default:
+		return "und"
+	}
+}
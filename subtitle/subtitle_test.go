@@ -0,0 +1,55 @@
+package subtitle
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const bccJson = `{
+	"font_size": 0.4,
+	"font_color": "#FFFFFF",
+	"background_alpha": 0.5,
+	"background_color": "#9C27B0",
+	"Stroke": "none",
+	"body": [
+		{"from": 0.5, "to": 2.25, "location": 2, "content": "第一句"},
+		{"from": 3661.001, "to": 3662, "location": 2, "content": "a < b\n\nnext line"}
+	]
+}`
+
+func TestParseBCC(t *testing.T) {
+	bcc, err := ParseBCC(strings.NewReader(bccJson))
+	require.Nil(t, err)
+	require.Len(t, bcc.Body, 2)
+	require.Equal(t, &Cue{From: 0.5, To: 2.25, Content: "第一句"}, bcc.Body[0])
+
+	_, err = ParseBCC(strings.NewReader("{"))
+	require.NotNil(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	bcc, err := ParseBCC(strings.NewReader(bccJson))
+	require.Nil(t, err)
+
+	srt := bytes.NewBuffer(nil)
+	require.Nil(t, Write(srt, bcc.Body, "srt"))
+	require.Equal(t, "1\n00:00:00,500 --> 00:00:02,250\n第一句\n\n"+
+		"2\n01:01:01,001 --> 01:01:02,000\na < b\nnext line\n\n", srt.String())
+
+	vtt := bytes.NewBuffer(nil)
+	require.Nil(t, Write(vtt, bcc.Body, "vtt"))
+	require.Equal(t, "WEBVTT\n\n00:00:00.500 --> 00:00:02.250\n第一句\n\n"+
+		"01:01:01.001 --> 01:01:02.000\na &lt; b\nnext line\n\n", vtt.String())
+
+	require.NotNil(t, Write(vtt, bcc.Body, "ass"))
+}
+
+func TestISO639(t *testing.T) {
+	require.Equal(t, "chi", ISO639("zh-CN"))
+	require.Equal(t, "chi", ISO639("ai-zh"))
+	require.Equal(t, "eng", ISO639("en-US"))
+	require.Equal(t, "und", ISO639("xx"))
+}
@@ -0,0 +1,79 @@
+package verify
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	flvHeaderSize = 9
+	flvTagSize    = 11 // header of tag
+
+	flvTagAudio  = 8
+	flvTagVideo  = 9
+	flvTagScript = 18
+)
+
+// walkFLV check header and every tag of flv, size of tag should match the previous tag size after it
+func walkFLV(f *os.File, r *Report) error {
+	head, err := readAt(f, 0, flvHeaderSize, "header")
+	if err != nil {
+		return err
+	}
+	if head[3] != 1 {
+		return fmt.Errorf("%w: unsupported flv version %v", ErrCorrupt, head[3])
+	}
+	off := int64(binary.BigEndian.Uint32(head[5:9]))
+	if off < flvHeaderSize {
+		return fmt.Errorf("%w: invalid flv header size %v", ErrCorrupt, off)
+	}
+	prev, err := readAt(f, off, 4, "previous tag size")
+	if err != nil {
+		return err
+	}
+	if size := binary.BigEndian.Uint32(prev); size != 0 {
+		return fmt.Errorf("%w: the first previous tag size is %v, want 0", ErrCorrupt, size)
+	}
+	off += 4
+
+	for off < r.Size {
+		tag, err := readAt(f, off, flvTagSize, "tag")
+		if err != nil {
+			return err
+		}
+		switch typ := tag[0] & 0x1f; typ {
+		case flvTagAudio, flvTagVideo, flvTagScript:
+		default:
+			return fmt.Errorf("%w: invalid tag type %v at offset %v", ErrCorrupt, typ, off)
+		}
+		if stream := uint24(tag[8:11]); stream != 0 {
+			return fmt.Errorf("%w: stream id of tag at offset %v is %v, want 0", ErrCorrupt, off, stream)
+		}
+		dataSize := int64(uint24(tag[1:4]))
+		ts := int64(uint24(tag[4:7])) | int64(tag[7])<<24
+
+		prevOff := off + flvTagSize + dataSize
+		if prevOff+4 > r.Size {
+			return fmt.Errorf("%w: tag at offset %v ends at %v, file ends at %v", ErrTruncated, off, prevOff+4, r.Size)
+		}
+		prev, err := readAt(f, prevOff, 4, "previous tag size")
+		if err != nil {
+			return err
+		}
+		if size := int64(binary.BigEndian.Uint32(prev)); size != flvTagSize+dataSize {
+			return fmt.Errorf("%w: previous tag size at offset %v is %v, want %v", ErrCorrupt, prevOff, size, flvTagSize+dataSize)
+		}
+		r.Tags++
+		if d := time.Duration(ts) * time.Millisecond; d > r.Duration {
+			r.Duration = d
+		}
+		off = prevOff + 4
+	}
+	return nil
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
@@ -0,0 +1,133 @@
+package verify
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+)
+
+// containers are boxes made of child boxes only, children of them are walked too
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"edts": true,
+	"dinf": true,
+	"mvex": true,
+	"moof": true,
+	"traf": true,
+}
+
+// walkMP4 check top level boxes of mp4 and children of containers, every box should fit in its parent,
+// moov is required
+func walkMP4(f *os.File, r *Report) error {
+	if err := walkBoxes(f, r, 0, r.Size, 0); err != nil {
+		return err
+	}
+	for _, typ := range r.Boxes {
+		if typ == "moov" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no moov box", ErrCorrupt)
+}
+
+// walkBoxes walk boxes in [off, end) of depth
+func walkBoxes(f *os.File, r *Report, off, end int64, depth int) error {
+	for off < end {
+		head, err := readAt(f, off, 8, "box header")
+		if err != nil {
+			return err
+		}
+		typ := string(head[4:8])
+		if !isBoxType(head[4:8]) {
+			return fmt.Errorf("%w: invalid box type %x at offset %v", ErrCorrupt, head[4:8], off)
+		}
+		size, headSize := int64(binary.BigEndian.Uint32(head[:4])), int64(8)
+		switch size {
+		case 0:
+			// box extends to end of file, only the last top level one can do so
+			if depth > 0 {
+				return fmt.Errorf("%w: size of box %v at offset %v is 0", ErrCorrupt, typ, off)
+			}
+			size = end - off
+		case 1:
+			large, err := readAt(f, off+8, 8, "box header")
+			if err != nil {
+				return err
+			}
+			size, headSize = int64(binary.BigEndian.Uint64(large)), 16
+		}
+		if size < headSize {
+			return fmt.Errorf("%w: size of box %v at offset %v is %v", ErrCorrupt, typ, off, size)
+		}
+		if off+size > end {
+			if depth == 0 {
+				return fmt.Errorf("%w: box %v at offset %v ends at %v, file ends at %v", ErrTruncated, typ, off, off+size, end)
+			}
+			return fmt.Errorf("%w: box %v at offset %v exceeds its parent", ErrCorrupt, typ, off)
+		}
+
+		if depth == 0 {
+			r.Boxes = append(r.Boxes, typ)
+		}
+		switch {
+		case containers[typ]:
+			if err := walkBoxes(f, r, off+headSize, off+size, depth+1); err != nil {
+				return err
+			}
+		case typ == "mvhd":
+			if err := readMvhd(f, r, off+headSize, size-headSize); err != nil {
+				return err
+			}
+		}
+		off += size
+	}
+	return nil
+}
+
+// readMvhd read duration of movie from mvhd box
+func readMvhd(f *os.File, r *Report, off, size int64) error {
+	if size < 20 {
+		return fmt.Errorf("%w: mvhd at offset %v is too small", ErrCorrupt, off)
+	}
+	body, err := readAt(f, off, int(min64(size, 32)), "mvhd")
+	if err != nil {
+		return err
+	}
+	var timescale, duration uint64
+	if body[0] == 1 {
+		if size < 32 {
+			return fmt.Errorf("%w: mvhd at offset %v is too small", ErrCorrupt, off)
+		}
+		timescale = uint64(binary.BigEndian.Uint32(body[20:24]))
+		duration = binary.BigEndian.Uint64(body[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(body[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+	}
+	if timescale > 0 {
+		r.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	}
+	return nil
+}
+
+// isBoxType report whether b is a box type made of printable ascii
+func isBoxType(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,139 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+)
+
+var (
+	// ErrSize is returned if size of file is not the expected one
+	ErrSize = errors.New("size mismatch")
+	// ErrChecksum is returned if md5 of file is not the expected one
+	ErrChecksum = errors.New("md5 mismatch")
+	// ErrHole is returned if a fragment of file is all zeros, it's left by a fragment never written
+	ErrHole = errors.New("zeroed fragment")
+	// ErrTruncated is returned if the last tag or box of file is incomplete
+	ErrTruncated = errors.New("truncated")
+	// ErrCorrupt is returned if structure of container is invalid
+	ErrCorrupt = errors.New("corrupt")
+)
+
+// Expect is what file should be, zero fields are not checked
+type Expect struct {
+	Size   int64
+	MD5    string // hex, case insensitive
+	Format string // flv or mp4, detected from content if empty
+}
+
+// Report is what is found in file, it's filled as far as verification goes
+type Report struct {
+	Size     int64
+	MD5      string
+	Format   string
+	Tags     int           // tags of flv
+	Boxes    []string      // types of top level boxes of mp4
+	Duration time.Duration // timestamp of the last tag of flv, duration in mvhd of mp4
+}
+
+// File verify size, md5, zeroed fragments and container structure of file name in order,
+// the first problem found is returned and wraps one of the errors above
+func File(name string, exp *Expect) (*Report, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Size: st.Size()}
+	if exp.Size > 0 && r.Size != exp.Size {
+		return r, fmt.Errorf("%w: size is %v, want %v", ErrSize, r.Size, exp.Size)
+	}
+	if err := scan(f, r); err != nil {
+		return r, err
+	}
+	if exp.MD5 != "" && !strings.EqualFold(r.MD5, exp.MD5) {
+		return r, fmt.Errorf("%w: md5 is %v, want %v", ErrChecksum, r.MD5, strings.ToLower(exp.MD5))
+	}
+
+	r.Format, err = detect(f)
+	if err != nil {
+		return r, err
+	}
+	if exp.Format != "" && exp.Format != r.Format {
+		return r, fmt.Errorf("%w: format is %v, want %v", ErrCorrupt, r.Format, exp.Format)
+	}
+	if r.Format == "flv" {
+		return r, walkFLV(f, r)
+	}
+	return r, walkMP4(f, r)
+}
+
+// scan read file in fragments as downloaded to compute md5, a fragment failed to download is
+// left zeroed by fallocate, so whole fragments of zeros are reported as holes
+func scan(f *os.File, r *Report) error {
+	var (
+		hash  = md5.New()
+		buf   = make([]byte, consts.FragSize)
+		zeros = make([]byte, consts.FragSize)
+		holes []int64
+	)
+	for off := int64(0); off < r.Size; off += consts.FragSize {
+		n, err := io.ReadFull(io.NewSectionReader(f, off, consts.FragSize), buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		hash.Write(buf[:n])
+		if bytes.Equal(buf[:n], zeros[:n]) {
+			holes = append(holes, off)
+		}
+	}
+	r.MD5 = hex.EncodeToString(hash.Sum(nil))
+	if len(holes) > 0 {
+		return fmt.Errorf("%w: %v fragments of %v bytes are zeros, the first is at offset %v",
+			ErrHole, len(holes), consts.FragSize, holes[0])
+	}
+	return nil
+}
+
+// mp4Heads are types of box mp4 files begin with
+var mp4Heads = map[string]bool{"ftyp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true}
+
+// detect format of file by its header
+func detect(f *os.File) (string, error) {
+	head := make([]byte, 8)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return "", fmt.Errorf("%w: file is too small", ErrTruncated)
+	}
+	switch {
+	case string(head[:3]) == "FLV":
+		return "flv", nil
+	case mp4Heads[string(head[4:8])]:
+		return "mp4", nil
+	}
+	return "", fmt.Errorf("%w: unknown format, header is %x", ErrCorrupt, head)
+}
+
+// readAt read n bytes at off, file ends before them is reported as truncated
+func readAt(f *os.File, off int64, n int, what string) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, off); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: %v at offset %v is incomplete", ErrTruncated, what, off)
+		}
+		return nil, err
+	}
+	return buf, nil
+}
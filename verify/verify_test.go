@@ -0,0 +1,136 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rammiah/bili-downloader/consts"
+	"github.com/stretchr/testify/require"
+)
+
+// flvFile build flv of tags alternating video and audio, tag i has size bytes of data and timestamp i*40ms
+func flvFile(tags, size int) []byte {
+	buf := bytes.NewBuffer(nil)
+	buf.Write([]byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9})
+	buf.Write([]byte{0, 0, 0, 0})
+	for i := 0; i < tags; i++ {
+		typ := byte(flvTagVideo)
+		if i%2 == 1 {
+			typ = flvTagAudio
+		}
+		ts := i * 40
+		buf.Write([]byte{typ, byte(size >> 16), byte(size >> 8), byte(size), byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24), 0, 0, 0})
+		buf.Write(bytes.Repeat([]byte{byte(i + 1)}, size))
+		binary.Write(buf, binary.BigEndian, uint32(flvTagSize+size))
+	}
+	return buf.Bytes()
+}
+
+func box(typ string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+// mp4File build mp4 of ftyp, moov with mvhd of 10.5s and mdat
+func mp4File() []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 10500)
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41")),
+		box("moov", box("mvhd", mvhd), box("trak", box("tkhd", make([]byte, 84)), box("mdia", box("mdhd", make([]byte, 24))))),
+		box("mdat", bytes.Repeat([]byte{1, 2, 3}, 1000)),
+	}, nil)
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	name := filepath.Join(t.TempDir(), "a")
+	require.Nil(t, os.WriteFile(name, data, 0644))
+	return name
+}
+
+func TestFileFLV(t *testing.T) {
+	data := flvFile(10, 100)
+	sum := md5.Sum(data)
+	r, err := File(writeFile(t, data), &Expect{Size: int64(len(data)), MD5: hex.EncodeToString(sum[:]), Format: "flv"})
+	require.Nil(t, err)
+	require.Equal(t, "flv", r.Format)
+	require.Equal(t, 10, r.Tags)
+	require.Equal(t, 360*time.Millisecond, r.Duration)
+	require.Equal(t, hex.EncodeToString(sum[:]), r.MD5)
+
+	corrupt := flvFile(10, 100)
+	// header of the 4th tag is zeroed
+	off := 13 + 3*(flvTagSize+100+4)
+	copy(corrupt[off:], make([]byte, flvTagSize))
+	mismatch := flvFile(10, 100)
+	mismatch[len(mismatch)-1]++
+
+	for name, c := range map[string]struct {
+		data []byte
+		exp  *Expect
+		err  error
+		msg  string
+	}{
+		"size":      {data, &Expect{Size: 10}, ErrSize, "size is 1163, want 10"},
+		"md5":       {data, &Expect{MD5: "2a65e65f2db52d8ddc163c3d7c6cd7b2"}, ErrChecksum, "want 2a65e65f2db52d8ddc163c3d7c6cd7b2"},
+		"format":    {data, &Expect{Format: "mp4"}, ErrCorrupt, "format is flv, want mp4"},
+		"truncated": {data[:len(data)-50], &Expect{}, ErrTruncated, "tag at offset 1048 ends at 1163, file ends at 1113"},
+		"header":    {data[:8], &Expect{}, ErrTruncated, "header at offset 0 is incomplete"},
+		"tag":       {corrupt, &Expect{}, ErrCorrupt, "invalid tag type 0 at offset 358"},
+		"prev size": {mismatch, &Expect{}, ErrCorrupt, "previous tag size at offset 1159 is 112, want 111"},
+		"unknown":   {[]byte("hello world"), &Expect{}, ErrCorrupt, "unknown format"},
+	} {
+		_, err := File(writeFile(t, c.data), c.exp)
+		require.True(t, errors.Is(err, c.err), "%v: %v", name, err)
+		require.Contains(t, err.Error(), c.msg, name)
+	}
+}
+
+func TestFileHole(t *testing.T) {
+	// 3 tags of 4M, the second fragment is never written
+	data := flvFile(3, 4*consts.MB)
+	copy(data[consts.FragSize:], make([]byte, len(data)-consts.FragSize))
+	r, err := File(writeFile(t, data), &Expect{Size: int64(len(data))})
+	require.True(t, errors.Is(err, ErrHole), err)
+	require.Contains(t, err.Error(), "1 fragments of 8388608 bytes are zeros, the first is at offset 8388608")
+	require.NotEmpty(t, r.MD5)
+}
+
+func TestFileMP4(t *testing.T) {
+	data := mp4File()
+	r, err := File(writeFile(t, data), &Expect{Size: int64(len(data)), Format: "mp4"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"ftyp", "moov", "mdat"}, r.Boxes)
+	require.Equal(t, 10500*time.Millisecond, r.Duration)
+
+	// size of tkhd exceeds trak
+	exceed := mp4File()
+	off := bytes.Index(exceed, []byte("tkhd")) - 4
+	binary.BigEndian.PutUint32(exceed[off:], 1000)
+	noMoov := bytes.Join([][]byte{box("ftyp", []byte("isom")), box("mdat", []byte{1})}, nil)
+
+	for name, c := range map[string]struct {
+		data []byte
+		err  error
+		msg  string
+	}{
+		"truncated": {data[:len(data)-10], ErrTruncated, "box mdat at offset 288 ends at 3296, file ends at 3286"},
+		"exceed":    {exceed, ErrCorrupt, "box tkhd at offset 156 exceeds its parent"},
+		"no moov":   {noMoov, ErrCorrupt, "no moov box"},
+	} {
+		_, err := File(writeFile(t, c.data), &Expect{})
+		require.True(t, errors.Is(err, c.err), "%v: %v", name, err)
+		require.Contains(t, err.Error(), c.msg, name)
+	}
+}